@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+)
+
+func main() {
+	host := flag.String("h", "localhost", "Host of SMTP server")
+	port := flag.Int("p", 25, "Port of SMTP server")
+	security := flag.String("security", notify.SMTPSecurityNone, "Connection security: tls, starttls or none")
+	username := flag.String("user", "", "Username for authentication")
+	password := flag.String("pwd", "", "Password for authentication")
+	from := flag.String("from", "avamon-bot@localhost", "Address of the sender")
+	count := flag.Int("n", 1, "Number of events in the email")
+
+	flag.Parse()
+
+	server := notify.SMTPServer{
+		Host:     *host,
+		Port:     *port,
+		Security: *security,
+		Username: *username,
+		Password: *password,
+		From:     *from,
+	}
+	if err := server.Validate(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	email := notify.NewEmail(server, flag.Args())
+	// The batch is sent explicitly below.
+	email.BatchDelay = time.Hour
+	email.Retries = 0
+
+	for i := 1; i <= *count; i++ {
+		event := notify.SampleEvent(false)
+		event.IncidentID = fmt.Sprint(i)
+		event.Target.ID = uint(i)
+		event.Target.Title = fmt.Sprintf("Target %v", i)
+		event.Target.URL = fmt.Sprintf("http://example.com/%v", i)
+		email.Notify(event)
+	}
+	if err := email.Flush(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
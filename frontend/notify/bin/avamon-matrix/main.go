@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+)
+
+func main() {
+	homeserver := flag.String("hs", "https://matrix.org", "Base URL of the homeserver")
+	token := flag.String("token", "", "Access token of the sending user")
+	retries := flag.Int("retries", 0, "Retries after a failed attempt")
+
+	flag.Parse()
+
+	event := notify.SampleEvent(false)
+
+	failed := false
+	for _, room := range flag.Args() {
+		matrix := notify.NewMatrix(*homeserver, *token, room)
+		matrix.Retries = *retries
+		matrix.Log = notify.PrintLog{}
+		if err := matrix.Notify(event); err != nil {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+)
+
+func main() {
+	url := flag.String("url", notify.PagerDutyEventsURL, "Endpoint of the events API")
+	retries := flag.Int("retries", 0, "Retries after a failed attempt")
+	resolve := flag.Bool("resolve", false, "Send a resolve event instead of a trigger")
+
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Println("Usage: avamon-pager [-url URL] [-resolve] ROUTING-KEY")
+		os.Exit(1)
+	}
+
+	pager := notify.NewPager(*url, flag.Arg(0))
+	pager.Retries = *retries
+	pager.Log = notify.PrintLog{}
+
+	event := notify.SampleEvent(*resolve)
+	event.Target.Critical = true
+	fmt.Println("Dedup key:", notify.DedupKey(event.Target.ID, event.IncidentID))
+	if err := pager.Notify(event); err != nil {
+		os.Exit(1)
+	}
+}
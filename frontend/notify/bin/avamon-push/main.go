@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+)
+
+func main() {
+	kind := flag.String("kind", notify.PushNtfy, "Kind of the server: ntfy or gotify")
+	token := flag.String("token", "", "Access token of ntfy server")
+	retries := flag.Int("retries", 0, "Retries after a failed attempt")
+	up := flag.Bool("up", false, "Send a recovery instead of a failure")
+
+	flag.Parse()
+	if flag.NArg() != 2 {
+		fmt.Println("Usage: avamon-push [-kind ntfy|gotify] SERVER TOPIC-OR-APP-TOKEN")
+		os.Exit(1)
+	}
+
+	push := notify.NewPush(*kind, flag.Arg(0), flag.Arg(1))
+	push.Token = *token
+	push.Retries = *retries
+	push.Log = notify.PrintLog{}
+
+	if err := push.Notify(notify.SampleEvent(*up)); err != nil {
+		os.Exit(1)
+	}
+}
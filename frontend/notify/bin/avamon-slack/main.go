@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+)
+
+func main() {
+	token := flag.String("token", "", "Slack bot token, to post with chat.postMessage instead of a webhook")
+	channel := flag.String("channel", "", "Channel to post to with the token")
+	apiURL := flag.String("api", notify.SlackAPIURL, "Endpoint of chat.postMessage")
+	retries := flag.Int("retries", 0, "Retries after a failed attempt")
+
+	flag.Parse()
+
+	slack := notify.NewSlack(flag.Arg(0))
+	slack.Token = *token
+	slack.Channel = *channel
+	slack.APIURL = *apiURL
+	slack.Retries = *retries
+	slack.Log = notify.PrintLog{}
+	if slack.URL == "" && slack.Token == "" {
+		fmt.Println("Usage: avamon-slack WEBHOOK-URL or avamon-slack -token TOKEN -channel CHANNEL")
+		os.Exit(1)
+	}
+
+	// An alert followed by the recovery, which is threaded under it if
+	// the token is used.
+	started := time.Now().Add(-5 * time.Minute)
+	for _, event := range []notify.Event{notify.SampleEvent(false), notify.SampleEvent(true)} {
+		event.IncidentStart = started
+		if err := slack.Notify(event); err != nil {
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+)
+
+func main() {
+	secret := flag.String("secret", "", "Secret to sign requests with")
+	retries := flag.Int("retries", 0, "Retries after a failed attempt")
+	down := flag.Bool("down", false, "Send a failure instead of a recovery")
+
+	flag.Parse()
+
+	event := notify.SampleEvent(!*down)
+
+	failed := false
+	for _, url := range flag.Args() {
+		webhook := notify.NewWebhook(url, *secret)
+		webhook.Retries = *retries
+		webhook.Log = notify.PrintLog{}
+		if err := webhook.Notify(event); err != nil {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
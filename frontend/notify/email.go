@@ -0,0 +1,375 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Connection security of SMTP servers.
+const (
+	// Implicit TLS, usually on port 465.
+	SMTPSecurityTLS = "tls"
+	// Plain connection upgraded with STARTTLS command, usually on port 587.
+	SMTPSecuritySTARTTLS = "starttls"
+	// Plain connection, e.g. to a local relay.
+	SMTPSecurityNone = "none"
+)
+
+// DefaultBatchDelay is how long Email waits for more events before sending
+// an email, unless configured otherwise.
+const DefaultBatchDelay = 30 * time.Second
+
+// SMTPServer describes how to connect to an SMTP server.
+type SMTPServer struct {
+	Host string
+	Port int
+	// One of SMTPSecurity* constants.
+	Security string
+	// Credentials for PLAIN authentication, skipped if Username is empty.
+	Username string
+	Password string
+	// Address of the sender.
+	From string
+	// Timeout of connection and every command.
+	Timeout time.Duration
+}
+
+// Validate checks the configuration for errors.
+func (s SMTPServer) Validate() error {
+	if s.Host == "" || s.Port == 0 {
+		return fmt.Errorf("SMTP host and port must be set")
+	}
+	if s.From == "" {
+		return fmt.Errorf("SMTP sender address must be set")
+	}
+	switch s.Security {
+	case SMTPSecurityTLS, SMTPSecuritySTARTTLS, SMTPSecurityNone:
+		return nil
+	}
+	return fmt.Errorf("Unknown SMTP security %q, use tls, starttls or none", s.Security)
+}
+
+func (s SMTPServer) dial() (*smtp.Client, error) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	tlsConfig := &tls.Config{ServerName: s.Host}
+
+	var conn net.Conn
+	var err error
+	if s.Security == SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	client, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if s.Security == SMTPSecuritySTARTTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	if s.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", s.Username, s.Password, s.Host)); err != nil {
+			client.Close()
+			return nil, err
+		}
+	}
+	return client, nil
+}
+
+// Send sends the message to the recipients.
+func (s SMTPServer) Send(to []string, msg []byte) error {
+	client, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := client.Mail(s.From); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// Email sends events by email. Events arriving within BatchDelay after
+// the first one are sent in a single email, so that an outage of many
+// targets doesn't flood the recipients.
+type Email struct {
+	Server SMTPServer
+	To     []string
+	// If zero, every event is sent immediately and Notify returns the result
+	// of sending. Otherwise Notify only queues the event and errors are only
+	// written to Log.
+	BatchDelay time.Duration
+	// Time zone of times in emails, UTC if nil.
+	Location *time.Location
+	Retries  int
+	Backoff  time.Duration
+	// Optional log of delivery attempts. Every attempt to send a batch is
+	// logged for each of its events.
+	Log DeliveryLog
+
+	mu    sync.Mutex
+	batch []Event
+}
+
+// NewEmail creates an Email with default batching and retry policy.
+func NewEmail(server SMTPServer, to []string) *Email {
+	return &Email{
+		Server:     server,
+		To:         to,
+		BatchDelay: DefaultBatchDelay,
+		Retries:    DefaultRetries,
+		Backoff:    DefaultBackoff,
+	}
+}
+
+// Notify implements Notifier.
+func (m *Email) Notify(e Event) error {
+	if m.BatchDelay == 0 {
+		return m.send([]Event{e})
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.batch = append(m.batch, e)
+	if len(m.batch) == 1 {
+		time.AfterFunc(m.BatchDelay, func() {
+			if err := m.Flush(); err != nil {
+				fmt.Printf("Could not send email to %v: %v\n", strings.Join(m.To, ", "), err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush sends the queued events without waiting for the end of BatchDelay.
+func (m *Email) Flush() error {
+	m.mu.Lock()
+	batch := m.batch
+	m.batch = nil
+	m.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+	return m.send(batch)
+}
+
+func (m *Email) send(events []Event) error {
+	msg, err := buildEmail(m.Server.From, m.To, events, m.Location)
+	if err != nil {
+		return err
+	}
+	return retry(m.Retries, m.Backoff, func(attempt int) error {
+		err := m.Server.Send(m.To, msg)
+		for _, e := range events {
+			logDelivery(m.Log, Delivery{
+				Notifier:    "email",
+				Destination: strings.Join(m.To, ", "),
+				IncidentID:  e.IncidentID,
+				TargetID:    e.Target.ID,
+				Attempt:     attempt,
+				Time:        time.Now(),
+				Err:         err,
+			})
+		}
+		return err
+	})
+}
+
+// emailItem is an event prepared for email templates. It contains the same
+// data as notifications in Telegram.
+type emailItem struct {
+	Event        string
+	Title        string
+	URL          string
+	Tags         string
+	Time         string
+	Status       string
+	OK           bool
+	ResponseTime string
+	Error        string
+	HTTPStatus   int
+	IncidentID   string
+}
+
+func newEmailItem(e Event, loc *time.Location) emailItem {
+	p := e.Payload()
+	return emailItem{
+		Event:        strings.ToUpper(p.Event),
+		Title:        p.Target.Title,
+		URL:          p.Target.URL,
+		Tags:         strings.Join(p.Target.Tags, ", "),
+		Time:         e.Time.In(loc).Format("2006-01-02 15:04:05 MST"),
+		Status:       p.Status.Type,
+		OK:           p.Status.OK,
+		ResponseTime: e.Status.ResponseTime.String(),
+		Error:        p.Status.Error,
+		HTTPStatus:   p.Status.HTTPStatusCode,
+		IncidentID:   p.IncidentID,
+	}
+}
+
+var emailTextTemplate = template.Must(template.New("text").Parse(
+	`{{range .}}{{.Event}}: {{.Title}} ({{.Status}})
+URL: {{.URL}}
+Time: {{.Time}}
+{{if .Tags}}Tags: {{.Tags}}
+{{end}}Response time: {{.ResponseTime}}
+{{if .Error}}Error: {{.Error}}
+{{end}}{{if .HTTPStatus}}HTTP status: {{.HTTPStatus}}
+{{end}}{{if .IncidentID}}Incident: {{.IncidentID}}
+{{end}}
+{{end}}`))
+
+var emailHTMLTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(
+	`<html><body>
+{{range .}}<div style="margin-bottom: 16px">
+<h3 style="color: {{if .OK}}#2e7d32{{else}}#c62828{{end}}">{{.Event}}: {{.Title}} ({{.Status}})</h3>
+<table>
+<tr><td><b>URL:</b></td><td><a href="{{.URL}}">{{.URL}}</a></td></tr>
+<tr><td><b>Time:</b></td><td>{{.Time}}</td></tr>
+{{if .Tags}}<tr><td><b>Tags:</b></td><td>{{.Tags}}</td></tr>
+{{end}}<tr><td><b>Response time:</b></td><td>{{.ResponseTime}}</td></tr>
+{{if .Error}}<tr><td><b>Error:</b></td><td>{{.Error}}</td></tr>
+{{end}}{{if .HTTPStatus}}<tr><td><b>HTTP status:</b></td><td>{{.HTTPStatus}}</td></tr>
+{{end}}{{if .IncidentID}}<tr><td><b>Incident:</b></td><td>{{.IncidentID}}</td></tr>
+{{end}}</table>
+</div>
+{{end}}</body></html>
+`))
+
+// emailSubject summarizes the events, e.g. "[DOWN] Example" or
+// "3 targets down, 1 up".
+func emailSubject(items []emailItem) string {
+	if len(items) == 1 {
+		return fmt.Sprintf("[%v] %v", items[0].Event, items[0].Title)
+	}
+	down, up := 0, 0
+	for _, item := range items {
+		if item.OK {
+			up++
+		} else {
+			down++
+		}
+	}
+	var parts []string
+	if down > 0 {
+		parts = append(parts, fmt.Sprintf("%v down", down))
+	}
+	if up > 0 {
+		parts = append(parts, fmt.Sprintf("%v up", up))
+	}
+	return fmt.Sprintf("%v targets changed status: %v", len(items), strings.Join(parts, ", "))
+}
+
+func writeQuotedPrintable(mw *multipart.Writer, contentType string, body []byte) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write(body); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// buildEmail renders the events into a multipart message with plain-text and
+// HTML alternatives.
+func buildEmail(from string, to []string, events []Event, loc *time.Location) ([]byte, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	var items []emailItem
+	for _, e := range events {
+		items = append(items, newEmailItem(e, loc))
+	}
+
+	var text, html bytes.Buffer
+	if err := emailTextTemplate.Execute(&text, items); err != nil {
+		return nil, err
+	}
+	if err := emailHTMLTemplate.Execute(&html, items); err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 12)
+	rand.Read(id)
+	domain := "avamon-bot"
+	if at := strings.LastIndex(from, "@"); at >= 0 {
+		domain = from[at+1:]
+	}
+
+	var msg bytes.Buffer
+	mw := multipart.NewWriter(&msg)
+	headers := []string{
+		"From: " + from,
+		"To: " + strings.Join(to, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", emailSubject(items)),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%v@%v>", hex.EncodeToString(id), domain),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	var out bytes.Buffer
+	out.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	if err := writeQuotedPrintable(mw, "text/plain; charset=utf-8", text.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(mw, "text/html; charset=utf-8", html.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	out.Write(msg.Bytes())
+	return out.Bytes(), nil
+}
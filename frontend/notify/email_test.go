@@ -0,0 +1,229 @@
+package notify
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// smtpMessage is an email received by smtpServer.
+type smtpMessage struct {
+	From string
+	To   []string
+	Data string
+}
+
+// smtpServer is a minimal SMTP server without extensions, which accepts
+// all emails.
+type smtpServer struct {
+	listener net.Listener
+	messages chan smtpMessage
+}
+
+func newSMTPServer(t *testing.T) *smtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{listener: l, messages: make(chan smtpMessage, 10)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%v\r\n", line) }
+
+	reply("220 localhost ESMTP")
+	var msg smtpMessage
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd := strings.ToUpper(line)
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg = smtpMessage{From: strings.Trim(line[len("MAIL FROM:"):], "<>")}
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.To = append(msg.To, strings.Trim(line[len("RCPT TO:"):], "<>"))
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 Go ahead")
+			var data []string
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				line = strings.TrimRight(line, "\r\n")
+				if line == "." {
+					break
+				}
+				data = append(data, strings.TrimPrefix(line, "."))
+			}
+			msg.Data = strings.Join(data, "\n")
+			s.messages <- msg
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Not implemented")
+		}
+	}
+}
+
+func (s *smtpServer) Close() {
+	s.listener.Close()
+}
+
+func (s *smtpServer) Server() SMTPServer {
+	host, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	return SMTPServer{
+		Host:     host,
+		Port:     portNum,
+		Security: SMTPSecurityNone,
+		From:     "bot@example.com",
+		Timeout:  5 * time.Second,
+	}
+}
+
+// expectMessage waits for the next email received by the server.
+func (s *smtpServer) expectMessage(t *testing.T) smtpMessage {
+	select {
+	case msg := <-s.messages:
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("No email was received")
+	}
+	return smtpMessage{}
+}
+
+func (s *smtpServer) expectNoMessage(t *testing.T, wait time.Duration) {
+	select {
+	case msg := <-s.messages:
+		t.Fatalf("Unexpected email: %+v", msg)
+	case <-time.After(wait):
+	}
+}
+
+func emailTestEvent(id uint, title string, ok bool) Event {
+	e := Event{
+		IncidentID: strconv.Itoa(int(id)),
+		Time:       time.Now(),
+		Target:     monitor.Target{ID: id, Title: title, URL: "http://example.com/" + title},
+		Status:     monitor.Status{Type: monitor.StatusHTTPError, Err: errors.New("bad status"), HTTPStatusCode: 502},
+	}
+	if ok {
+		e.Status = monitor.Status{Type: monitor.StatusOK}
+		e.PrevStatus = monitor.Status{Type: monitor.StatusTimeout}
+		e.PrevOK = true
+	}
+	return e
+}
+
+func TestEmailBatchesEvents(t *testing.T) {
+	srv := newSMTPServer(t)
+	defer srv.Close()
+
+	m := NewEmail(srv.Server(), []string{"ops@example.com", "dev@example.com"})
+	m.BatchDelay = 100 * time.Millisecond
+	for _, e := range []Event{
+		emailTestEvent(1, "Alpha", false),
+		emailTestEvent(2, "Beta", false),
+		emailTestEvent(3, "Gamma", true),
+	} {
+		if err := m.Notify(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	msg := srv.expectMessage(t)
+	if msg.From != "bot@example.com" {
+		t.Errorf("Sender is %q", msg.From)
+	}
+	if strings.Join(msg.To, ",") != "ops@example.com,dev@example.com" {
+		t.Errorf("Recipients are %v", msg.To)
+	}
+	if !strings.Contains(msg.Data, "Subject: 3 targets changed status: 2 down, 1 up") {
+		t.Errorf("Unexpected subject in:\n%v", msg.Data)
+	}
+	for _, text := range []string{"DOWN: Alpha", "DOWN: Beta", "UP: Gamma", "text/plain", "text/html"} {
+		if !strings.Contains(msg.Data, text) {
+			t.Errorf("Email doesn't contain %q:\n%v", text, msg.Data)
+		}
+	}
+	srv.expectNoMessage(t, 3*m.BatchDelay)
+
+	// The next event starts a new batch.
+	if err := m.Notify(emailTestEvent(1, "Alpha", true)); err != nil {
+		t.Fatal(err)
+	}
+	msg = srv.expectMessage(t)
+	if !strings.Contains(msg.Data, "Subject: [UP] Alpha") {
+		t.Errorf("Unexpected subject in:\n%v", msg.Data)
+	}
+}
+
+func TestEmailWithoutBatching(t *testing.T) {
+	srv := newSMTPServer(t)
+	defer srv.Close()
+
+	m := NewEmail(srv.Server(), []string{"ops@example.com"})
+	m.BatchDelay = 0
+	if err := m.Notify(emailTestEvent(1, "Alpha", false)); err != nil {
+		t.Fatal(err)
+	}
+	// The email has been sent by the time Notify returns.
+	select {
+	case msg := <-srv.messages:
+		if !strings.Contains(msg.Data, "Subject: [DOWN] Alpha") {
+			t.Errorf("Unexpected subject in:\n%v", msg.Data)
+		}
+	default:
+		t.Fatal("No email was sent")
+	}
+}
+
+func TestEmailFlush(t *testing.T) {
+	srv := newSMTPServer(t)
+	defer srv.Close()
+
+	m := NewEmail(srv.Server(), []string{"ops@example.com"})
+	m.BatchDelay = time.Hour
+	m.Notify(emailTestEvent(1, "Alpha", false))
+	m.Notify(emailTestEvent(2, "Beta", false))
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	msg := srv.expectMessage(t)
+	if !strings.Contains(msg.Data, "Subject: 2 targets changed status: 2 down") {
+		t.Errorf("Unexpected subject in:\n%v", msg.Data)
+	}
+	if err := m.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	srv.expectNoMessage(t, 100*time.Millisecond)
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"time"
+
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// Kinds of events.
+const (
+	// A target went down.
+	EventDown = "down"
+	// A target is OK, either after a failure or for the first time.
+	EventUp = "up"
+	// A failing target fails with another error.
+	EventChanged = "changed"
+)
+
+// Event is a change of a target's status delivered to notifiers.
+type Event struct {
+	// ID of the incident the event belongs to. An incident lasts from the
+	// first failure of a target until it's OK again, so the failure and the
+	// recovery share the ID. Empty for OK statuses outside incidents.
+	IncidentID string
+	// When the incident started, zero if unknown.
+	IncidentStart time.Time
+	Time          time.Time
+	Target        monitor.Target
+	Status        monitor.Status
+	// The previous status, valid if PrevOK is true.
+	PrevStatus monitor.Status
+	PrevOK     bool
+}
+
+// NewEvent constructs an event from the monitor's status update.
+func NewEvent(upd monitor.StatusUpdate, incidentID string, at time.Time) Event {
+	return Event{
+		IncidentID: incidentID,
+		Time:       at,
+		Target:     upd.Target,
+		Status:     upd.Status,
+		PrevStatus: upd.PrevStatus,
+		PrevOK:     upd.PrevOK,
+	}
+}
+
+// Duration returns how long the incident has lasted by the time of the event,
+// or zero if unknown.
+func (e Event) Duration() time.Duration {
+	if e.IncidentStart.IsZero() {
+		return 0
+	}
+	return e.Time.Sub(e.IncidentStart)
+}
+
+// Kind returns one of Event* constants.
+func (e Event) Kind() string {
+	if e.Status.Type == monitor.StatusOK {
+		return EventUp
+	}
+	if e.PrevOK && e.PrevStatus.Type != monitor.StatusOK {
+		return EventChanged
+	}
+	return EventDown
+}
+
+// IsRecovery reports whether the target is OK after a failure.
+func (e Event) IsRecovery() bool {
+	return e.Status.Type == monitor.StatusOK && e.PrevOK && e.PrevStatus.Type != monitor.StatusOK
+}
+
+// TargetPayload is the JSON representation of a target.
+type TargetPayload struct {
+	ID       uint     `json:"id"`
+	Title    string   `json:"title"`
+	URL      string   `json:"url"`
+	Tags     []string `json:"tags,omitempty"`
+	Critical bool     `json:"critical,omitempty"`
+}
+
+// StatusPayload is the JSON representation of a status.
+type StatusPayload struct {
+	Type           string  `json:"type"`
+	OK             bool    `json:"ok"`
+	Error          string  `json:"error,omitempty"`
+	ResponseTimeMs float64 `json:"response_time_ms"`
+	HTTPStatusCode int     `json:"http_status_code,omitempty"`
+}
+
+func newStatusPayload(s monitor.Status) StatusPayload {
+	payload := StatusPayload{
+		Type:           s.Type.String(),
+		OK:             s.Type == monitor.StatusOK,
+		ResponseTimeMs: float64(s.ResponseTime) / float64(time.Millisecond),
+		HTTPStatusCode: s.HTTPStatusCode,
+	}
+	if s.Err != nil {
+		payload.Error = s.Err.Error()
+	}
+	return payload
+}
+
+// Payload is the JSON representation of an event.
+type Payload struct {
+	// One of Event* constants.
+	Event      string         `json:"event"`
+	IncidentID string         `json:"incident_id,omitempty"`
+	Time       time.Time      `json:"time"`
+	Target     TargetPayload  `json:"target"`
+	Status     StatusPayload  `json:"status"`
+	PrevStatus *StatusPayload `json:"previous_status,omitempty"`
+}
+
+// Payload converts the event to its JSON representation.
+func (e Event) Payload() Payload {
+	payload := Payload{
+		Event:      e.Kind(),
+		IncidentID: e.IncidentID,
+		Time:       e.Time.UTC(),
+		Target: TargetPayload{
+			ID:       e.Target.ID,
+			Title:    e.Target.Title,
+			URL:      e.Target.URL,
+			Tags:     e.Target.Tags,
+			Critical: e.Target.Critical,
+		},
+		Status: newStatusPayload(e.Status),
+	}
+	if e.PrevOK {
+		prev := newStatusPayload(e.PrevStatus)
+		payload.PrevStatus = &prev
+	}
+	return payload
+}
@@ -0,0 +1,217 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// Defaults of Exec.
+const (
+	DefaultExecTimeout     = 30 * time.Second
+	DefaultExecConcurrency = 1
+	// Output of commands is truncated to this many bytes.
+	maxExecOutput = 64 * 1024
+)
+
+// ExecResult is the result of a command run by Exec.
+type ExecResult struct {
+	Event Event
+	// The command line, for display.
+	Command string
+	// Combined stdout and stderr, truncated if too long.
+	Output string
+	// -1 if the command didn't exit by itself.
+	ExitCode int
+	Duration time.Duration
+	// Nil if the command exited with zero code.
+	Err error
+}
+
+// Exec runs a command on events, e.g. a script restarting a failed service.
+// The command gets the event's payload (see Payload) as JSON on stdin and
+// its fields in AVAMON_* environment variables. Commands are not retried.
+type Exec struct {
+	// The program and its arguments. It's run directly, not with a shell.
+	Command []string
+	// Kinds of events to run the command on, all if empty.
+	Events []string
+	// The command is killed when the timeout passes.
+	Timeout time.Duration
+	// Maximum number of concurrently running commands. Other events wait
+	// for their turn.
+	Concurrency int
+	// Optional callback called with the result of every run.
+	OnResult func(ExecResult)
+	// Optional log of runs.
+	Log DeliveryLog
+
+	once  sync.Once
+	slots chan struct{}
+}
+
+// NewExec creates an Exec with default timeout and concurrency.
+func NewExec(command []string) *Exec {
+	return &Exec{
+		Command:     command,
+		Timeout:     DefaultExecTimeout,
+		Concurrency: DefaultExecConcurrency,
+	}
+}
+
+// execEnv returns the environment variables describing the event.
+func execEnv(e Event) []string {
+	env := []string{
+		"AVAMON_EVENT=" + e.Kind(),
+		"AVAMON_INCIDENT_ID=" + e.IncidentID,
+		"AVAMON_TIME=" + e.Time.UTC().Format(time.RFC3339),
+		"AVAMON_TARGET_ID=" + strconv.FormatUint(uint64(e.Target.ID), 10),
+		"AVAMON_TARGET_TITLE=" + e.Target.Title,
+		"AVAMON_TARGET_URL=" + e.Target.URL,
+		"AVAMON_TARGET_TAGS=" + strings.Join(e.Target.Tags, ","),
+		"AVAMON_TARGET_CRITICAL=" + strconv.FormatBool(e.Target.Critical),
+		"AVAMON_STATUS=" + e.Status.Type.String(),
+		"AVAMON_RESPONSE_TIME_MS=" + strconv.FormatInt(int64(e.Status.ResponseTime/time.Millisecond), 10),
+	}
+	if e.Status.Err != nil {
+		env = append(env, "AVAMON_ERROR="+e.Status.Err.Error())
+	}
+	if e.Status.HTTPStatusCode != 0 {
+		env = append(env, "AVAMON_HTTP_STATUS="+strconv.Itoa(e.Status.HTTPStatusCode))
+	}
+	if e.PrevOK {
+		env = append(env, "AVAMON_PREV_STATUS="+e.PrevStatus.Type.String())
+	}
+	return env
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	if room := lb.max - lb.buf.Len(); len(p) > room {
+		lb.buf.Write(p[:room])
+		lb.truncated = true
+	} else {
+		lb.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+func (lb *limitedBuffer) String() string {
+	if lb.truncated {
+		return lb.buf.String() + "\n(output truncated)"
+	}
+	return lb.buf.String()
+}
+
+func (x *Exec) matches(e Event) bool {
+	if len(x.Events) == 0 {
+		return true
+	}
+	for _, kind := range x.Events {
+		if kind == e.Kind() {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify implements Notifier. It returns after the command exits.
+func (x *Exec) Notify(e Event) error {
+	if len(x.Command) == 0 || !x.matches(e) {
+		return nil
+	}
+
+	x.once.Do(func() {
+		concurrency := x.Concurrency
+		if concurrency <= 0 {
+			concurrency = DefaultExecConcurrency
+		}
+		x.slots = make(chan struct{}, concurrency)
+	})
+	x.slots <- struct{}{}
+	defer func() { <-x.slots }()
+
+	res := x.run(e)
+	logDelivery(x.Log, Delivery{
+		Notifier:    "exec",
+		Destination: x.Command[0],
+		IncidentID:  e.IncidentID,
+		TargetID:    e.Target.ID,
+		Attempt:     1,
+		Time:        time.Now(),
+		Err:         res.Err,
+	})
+	if x.OnResult != nil {
+		x.OnResult(res)
+	}
+	return res.Err
+}
+
+func (x *Exec) run(e Event) ExecResult {
+	res := ExecResult{Event: e, Command: strings.Join(x.Command, " "), ExitCode: -1}
+
+	stdin, err := json.Marshal(e.Payload())
+	if err != nil {
+		res.Err = err
+		return res
+	}
+
+	timeout := x.Timeout
+	if timeout <= 0 {
+		timeout = DefaultExecTimeout
+	}
+
+	output := &limitedBuffer{max: maxExecOutput}
+	cmd := exec.Command(x.Command[0], x.Command[1:]...)
+	cmd.Env = append(os.Environ(), execEnv(e)...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = output
+	cmd.Stderr = output
+	// Kill children too, so that they don't hold the output open.
+	setProcessGroup(cmd)
+
+	started := time.Now()
+	if err := cmd.Start(); err != nil {
+		res.Err = err
+		return res
+	}
+	var timedOut int32
+	timer := time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&timedOut, 1)
+		killProcessGroup(cmd)
+	})
+	err = cmd.Wait()
+	timer.Stop()
+	res.Duration = time.Since(started)
+	res.Output = output.String()
+	if cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+			res.ExitCode = status.ExitStatus()
+		}
+	}
+
+	switch {
+	case atomic.LoadInt32(&timedOut) == 1:
+		res.Err = fmt.Errorf("Killed after %v timeout", timeout)
+	case err != nil:
+		res.Err = err
+	}
+	return res
+}
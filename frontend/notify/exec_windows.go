@@ -0,0 +1,13 @@
+package notify
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup does nothing, Windows has no process groups.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command, but not its children.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
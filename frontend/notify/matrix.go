@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Matrix sends events as messages to a Matrix room through the client-server
+// API on behalf of a user, usually a dedicated bot account.
+type Matrix struct {
+	// Base URL of the homeserver, e.g. "https://matrix.org".
+	Homeserver string
+	// Access token of the user, who must have joined the room.
+	Token string
+	// ID of the room, e.g. "!abcdef:matrix.org".
+	Room    string
+	Retries int
+	Backoff time.Duration
+	Client  *http.Client
+	// Optional log of delivery attempts.
+	Log DeliveryLog
+}
+
+// NewMatrix creates a Matrix notifier with default retry policy and timeout.
+func NewMatrix(homeserver, token, room string) *Matrix {
+	return &Matrix{
+		Homeserver: homeserver,
+		Token:      token,
+		Room:       room,
+		Retries:    DefaultRetries,
+		Backoff:    DefaultBackoff,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type matrixMessage struct {
+	MsgType       string `json:"msgtype"`
+	Body          string `json:"body"`
+	Format        string `json:"format"`
+	FormattedBody string `json:"formatted_body"`
+}
+
+// newMatrixMessage formats the event as plain text and HTML, which clients
+// display if they can.
+func newMatrixMessage(e Event) matrixMessage {
+	emoji := "🔴"
+	switch e.Kind() {
+	case EventUp:
+		emoji = "✅"
+	case EventChanged:
+		emoji = "🟠"
+	}
+
+	var text, rich []string
+	add := func(name, value string) {
+		text = append(text, fmt.Sprintf("%v: %v", name, value))
+		rich = append(rich, fmt.Sprintf("<b>%v:</b> %v", name, html.EscapeString(value)))
+	}
+
+	headline := emoji + " " + slackHeadline(e)
+	text = append(text, headline)
+	rich = append(rich, "<b>"+html.EscapeString(headline)+"</b>")
+	text = append(text, "URL: "+e.Target.URL)
+	rich = append(rich, fmt.Sprintf(
+		`<b>URL:</b> <a href="%v">%v</a>`,
+		html.EscapeString(e.Target.URL), html.EscapeString(e.Target.URL)))
+	if e.Status.Err != nil {
+		add("Error", e.Status.Err.Error())
+	}
+	add("Response time", e.Status.ResponseTime.Round(time.Millisecond).String())
+	if e.Status.HTTPStatusCode != 0 {
+		add("HTTP code", fmt.Sprint(e.Status.HTTPStatusCode))
+	}
+	if d := e.Duration(); d > 0 {
+		add("Duration", d.Round(time.Second).String())
+	}
+	if len(e.Target.Tags) > 0 {
+		add("Tags", strings.Join(e.Target.Tags, ", "))
+	}
+
+	return matrixMessage{
+		MsgType:       "m.text",
+		Body:          strings.Join(text, "\n"),
+		Format:        "org.matrix.custom.html",
+		FormattedBody: strings.Join(rich, "<br>"),
+	}
+}
+
+// Notify implements Notifier.
+func (m *Matrix) Notify(e Event) error {
+	body, err := json.Marshal(newMatrixMessage(e))
+	if err != nil {
+		return err
+	}
+
+	// The same transaction ID on every attempt lets the homeserver drop
+	// duplicates if a response was lost.
+	id := make([]byte, 12)
+	rand.Read(id)
+	txnID := hex.EncodeToString(id)
+
+	return retry(m.Retries, m.Backoff, func(attempt int) error {
+		err := m.send(txnID, body)
+		logDelivery(m.Log, Delivery{
+			Notifier:    "matrix",
+			Destination: m.Room,
+			IncidentID:  e.IncidentID,
+			TargetID:    e.Target.ID,
+			Attempt:     attempt,
+			Time:        time.Now(),
+			Err:         err,
+		})
+		return err
+	})
+}
+
+func (m *Matrix) send(txnID string, body []byte) error {
+	endpoint := fmt.Sprintf(
+		"%v/_matrix/client/v3/rooms/%v/send/m.room.message/%v",
+		strings.TrimSuffix(m.Homeserver, "/"), url.PathEscape(m.Room), txnID)
+	req, err := http.NewRequest("PUT", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "avamon-bot")
+	req.Header.Set("Authorization", "Bearer "+m.Token)
+
+	client := m.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
@@ -0,0 +1,81 @@
+// Package notify delivers status changes of monitored targets to external
+// services: webhooks and the like.
+package notify
+
+import (
+	"time"
+)
+
+// Notifier delivers events to an external service.
+type Notifier interface {
+	// Notify delivers the event, retrying if the notifier supports it, and
+	// returns the error of the last attempt.
+	Notify(e Event) error
+}
+
+// Delivery is an attempt to deliver an event.
+type Delivery struct {
+	// Kind of the notifier, e.g. "webhook".
+	Notifier string
+	// Where the event was delivered, e.g. the webhook's URL.
+	Destination string
+	IncidentID  string
+	TargetID    uint
+	// Number of the attempt, starting with 1.
+	Attempt int
+	Time    time.Time
+	// Nil if the attempt succeeded.
+	Err error
+}
+
+// DeliveryLog stores delivery attempts, e.g. to show them to users.
+type DeliveryLog interface {
+	LogDelivery(d Delivery) error
+}
+
+// Retry policy used by notifiers, unless configured otherwise.
+const (
+	DefaultRetries = 3
+	DefaultBackoff = 2 * time.Second
+)
+
+// permanentError is an error, which won't go away on retry.
+type permanentError struct {
+	err error
+}
+
+func (pe permanentError) Error() string {
+	return pe.err.Error()
+}
+
+// retry calls fn until it succeeds, returns a permanentError or there were
+// 1+retries attempts. The delay between attempts starts with backoff and
+// doubles every time. The returned error is the last one, unwrapped.
+func retry(retries int, backoff time.Duration, fn func(attempt int) error) error {
+	var err error
+	for attempt := 1; attempt <= retries+1; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		err = fn(attempt)
+		if err == nil {
+			return nil
+		}
+		if pe, ok := err.(permanentError); ok {
+			return pe.err
+		}
+	}
+	return err
+}
+
+// logDelivery writes the attempt to the log, if there is one.
+func logDelivery(log DeliveryLog, d Delivery) {
+	if log == nil {
+		return
+	}
+	if pe, ok := d.Err.(permanentError); ok {
+		d.Err = pe.err
+	}
+	log.LogDelivery(d)
+}
@@ -0,0 +1,247 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PagerDutyEventsURL is the endpoint of PagerDuty's Events API v2.
+const PagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Pager sends events to an incident management system with an Events API
+// style protocol: a trigger event when a target goes down and a resolve event
+// when it's up again, both carrying the same dedup key (see DedupKey).
+type Pager struct {
+	// Endpoint of the events API, PagerDutyEventsURL if empty.
+	URL string
+	// Integration key of the service to page.
+	RoutingKey string
+	// If true, only failures of critical targets are sent.
+	CriticalOnly bool
+	Retries      int
+	Backoff      time.Duration
+	Client       *http.Client
+	// Optional log of delivery attempts.
+	Log DeliveryLog
+}
+
+// NewPager creates a Pager with default retry policy and timeout.
+func NewPager(url, routingKey string) *Pager {
+	return &Pager{
+		URL:        url,
+		RoutingKey: routingKey,
+		Retries:    DefaultRetries,
+		Backoff:    DefaultBackoff,
+		Client:     &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// DedupKey returns the key identifying the incident of the target in
+// incident management systems.
+func DedupKey(targetID uint, incidentID string) string {
+	return fmt.Sprintf("avamon-target-%v-incident-%v", targetID, incidentID)
+}
+
+// ParseDedupKey is the reverse of DedupKey.
+func ParseDedupKey(key string) (targetID uint, incidentID string, err error) {
+	parts := strings.Split(key, "-")
+	if len(parts) != 5 || parts[0] != "avamon" || parts[1] != "target" || parts[3] != "incident" || parts[4] == "" {
+		return 0, "", fmt.Errorf("Invalid dedup key %q", key)
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 0)
+	if err != nil {
+		return 0, "", fmt.Errorf("Invalid dedup key %q", key)
+	}
+	return uint(id), parts[4], nil
+}
+
+type pagerLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+type pagerPayload struct {
+	Summary       string  `json:"summary"`
+	Source        string  `json:"source"`
+	Severity      string  `json:"severity"`
+	Timestamp     string  `json:"timestamp"`
+	CustomDetails Payload `json:"custom_details"`
+}
+
+type pagerEvent struct {
+	RoutingKey  string        `json:"routing_key"`
+	EventAction string        `json:"event_action"`
+	DedupKey    string        `json:"dedup_key"`
+	Payload     *pagerPayload `json:"payload,omitempty"`
+	Links       []pagerLink   `json:"links,omitempty"`
+}
+
+// newPagerEvent converts the event to a trigger or resolve event. It returns
+// nil if the event shouldn't be sent.
+func (p *Pager) newPagerEvent(e Event) *pagerEvent {
+	if e.IncidentID == "" {
+		return nil
+	}
+	if p.CriticalOnly && !e.Target.Critical {
+		return nil
+	}
+	pe := &pagerEvent{
+		RoutingKey: p.RoutingKey,
+		DedupKey:   DedupKey(e.Target.ID, e.IncidentID),
+	}
+	if e.Kind() == EventUp {
+		pe.EventAction = "resolve"
+		return pe
+	}
+
+	severity := "error"
+	if e.Target.Critical {
+		severity = "critical"
+	}
+	// Repeated triggers with the same dedup key update the open alert.
+	pe.EventAction = "trigger"
+	pe.Payload = &pagerPayload{
+		Summary:       slackHeadline(e),
+		Source:        e.Target.URL,
+		Severity:      severity,
+		Timestamp:     e.Time.UTC().Format(time.RFC3339),
+		CustomDetails: e.Payload(),
+	}
+	pe.Links = []pagerLink{{Href: e.Target.URL, Text: e.Target.Title}}
+	return pe
+}
+
+// Notify implements Notifier.
+func (p *Pager) Notify(e Event) error {
+	pe := p.newPagerEvent(e)
+	if pe == nil {
+		return nil
+	}
+	body, err := json.Marshal(pe)
+	if err != nil {
+		return err
+	}
+
+	url := p.URL
+	if url == "" {
+		url = PagerDutyEventsURL
+	}
+	return retry(p.Retries, p.Backoff, func(attempt int) error {
+		err := p.post(url, body)
+		logDelivery(p.Log, Delivery{
+			Notifier:    "pager",
+			Destination: url,
+			IncidentID:  e.IncidentID,
+			TargetID:    e.Target.ID,
+			Attempt:     attempt,
+			Time:        time.Now(),
+			Err:         err,
+		})
+		return err
+	})
+}
+
+func (p *Pager) post(url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "avamon-bot")
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// Actions of acknowledgements.
+const (
+	AckActionAcknowledge   = "acknowledge"
+	AckActionUnacknowledge = "unacknowledge"
+)
+
+// Ack is a change of an incident's acknowledgement in an incident management
+// system, sent back to the bot.
+type Ack struct {
+	DedupKey string `json:"dedup_key"`
+	// One of AckAction* constants.
+	Action string `json:"action"`
+	// Who acknowledged the incident, optional.
+	By string `json:"by"`
+}
+
+// ErrUnknownIncident is returned by AckHandler.Handle if there is no incident
+// with the dedup key.
+var ErrUnknownIncident = errors.New("Unknown incident")
+
+// AckHandler receives acknowledgements as JSON POSTed by an incident
+// management system or a relay in front of it:
+//
+//	{"dedup_key": "avamon-target-1-incident-42", "action": "acknowledge", "by": "alice"}
+//
+// Requests must carry the token in "Authorization: Bearer TOKEN" header.
+type AckHandler struct {
+	Token string
+	// Handle applies the acknowledgement to the incident.
+	Handle func(targetID uint, incidentID string, ack Ack) error
+}
+
+func (h *AckHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if h.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.Token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var ack Ack
+	if err := json.Unmarshal(body, &ack); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ack.Action != AckActionAcknowledge && ack.Action != AckActionUnacknowledge {
+		http.Error(w, "Action must be acknowledge or unacknowledge", http.StatusBadRequest)
+		return
+	}
+	targetID, incidentID, err := ParseDedupKey(ack.DedupKey)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = h.Handle(targetID, incidentID, ack)
+	if err == ErrUnknownIncident {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,183 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+func TestParseDedupKey(t *testing.T) {
+	targetID, incidentID, err := ParseDedupKey(DedupKey(12, "34"))
+	if err != nil || targetID != 12 || incidentID != "34" {
+		t.Fatalf("ParseDedupKey(DedupKey(12, 34)) = %v, %q, %v", targetID, incidentID, err)
+	}
+
+	for _, key := range []string{
+		"",
+		"avamon-target-12",
+		"avamon-target-x-incident-34",
+		"avamon-target--incident-34",
+		"avamon-target-12-incident-",
+		"other-target-12-incident-34",
+		"avamon-target-12-incident-34-5",
+	} {
+		if _, _, err := ParseDedupKey(key); err == nil {
+			t.Errorf("ParseDedupKey(%q) succeeded", key)
+		}
+	}
+}
+
+// pagerServer records events POSTed to it.
+func pagerServer(t *testing.T, events *[]pagerEvent) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type is %q", ct)
+		}
+		var pe pagerEvent
+		if err := json.NewDecoder(r.Body).Decode(&pe); err != nil {
+			t.Error(err)
+		}
+		*events = append(*events, pe)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+}
+
+func TestPagerNotify(t *testing.T) {
+	var events []pagerEvent
+	srv := pagerServer(t, &events)
+	defer srv.Close()
+
+	target := monitor.Target{ID: 7, Title: "Site", URL: "http://example.com", Critical: true}
+	down := Event{
+		IncidentID: "3",
+		Time:       time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Target:     target,
+		Status:     monitor.Status{Type: monitor.StatusTimeout, Err: errors.New("timeout")},
+		PrevOK:     true,
+	}
+	up := down
+	up.Status = monitor.Status{Type: monitor.StatusOK}
+	up.PrevStatus = down.Status
+
+	p := NewPager(srv.URL, "key")
+	for _, e := range []Event{down, up} {
+		if err := p.Notify(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("%v events were sent, want 2", len(events))
+	}
+	trigger, resolve := events[0], events[1]
+	if trigger.EventAction != "trigger" || trigger.RoutingKey != "key" || trigger.DedupKey != "avamon-target-7-incident-3" {
+		t.Errorf("Unexpected trigger event: %+v", trigger)
+	}
+	if trigger.Payload == nil {
+		t.Fatal("Trigger event has no payload")
+	}
+	if trigger.Payload.Severity != "critical" || trigger.Payload.Source != "http://example.com" ||
+		trigger.Payload.Timestamp != "2020-01-02T03:04:05Z" || trigger.Payload.CustomDetails.Event != EventDown {
+		t.Errorf("Unexpected trigger payload: %+v", trigger.Payload)
+	}
+	if len(trigger.Links) != 1 || trigger.Links[0].Href != "http://example.com" {
+		t.Errorf("Unexpected trigger links: %+v", trigger.Links)
+	}
+	if resolve.EventAction != "resolve" || resolve.DedupKey != trigger.DedupKey || resolve.Payload != nil {
+		t.Errorf("Unexpected resolve event: %+v", resolve)
+	}
+}
+
+func TestPagerSkipsEvents(t *testing.T) {
+	var events []pagerEvent
+	srv := pagerServer(t, &events)
+	defer srv.Close()
+
+	p := NewPager(srv.URL, "key")
+	p.CriticalOnly = true
+	normal := Event{
+		IncidentID: "1",
+		Target:     monitor.Target{ID: 1},
+		Status:     monitor.Status{Type: monitor.StatusTimeout, Err: errors.New("timeout")},
+	}
+	outsideIncident := Event{
+		Target: monitor.Target{ID: 2, Critical: true},
+		Status: monitor.Status{Type: monitor.StatusOK},
+	}
+	for _, e := range []Event{normal, outsideIncident} {
+		if err := p.Notify(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(events) != 0 {
+		t.Errorf("Events were sent: %+v", events)
+	}
+}
+
+func TestAckHandler(t *testing.T) {
+	var handled []Ack
+	h := &AckHandler{
+		Token: "secret",
+		Handle: func(targetID uint, incidentID string, ack Ack) error {
+			if targetID != 7 {
+				return ErrUnknownIncident
+			}
+			if incidentID == "fail" {
+				return errors.New("database is down")
+			}
+			handled = append(handled, ack)
+			return nil
+		},
+	}
+
+	cases := []struct {
+		method string
+		token  string
+		body   string
+		code   int
+	}{
+		{"GET", "secret", "", http.StatusMethodNotAllowed},
+		{"POST", "", `{"dedup_key": "avamon-target-7-incident-1", "action": "acknowledge"}`, http.StatusUnauthorized},
+		{"POST", "wrong", `{"dedup_key": "avamon-target-7-incident-1", "action": "acknowledge"}`, http.StatusUnauthorized},
+		{"POST", "secret", `{`, http.StatusBadRequest},
+		{"POST", "secret", `{"dedup_key": "avamon-target-7-incident-1", "action": "close"}`, http.StatusBadRequest},
+		{"POST", "secret", `{"dedup_key": "incident-1", "action": "acknowledge"}`, http.StatusBadRequest},
+		{"POST", "secret", `{"dedup_key": "avamon-target-8-incident-1", "action": "acknowledge"}`, http.StatusNotFound},
+		{"POST", "secret", `{"dedup_key": "avamon-target-7-incident-fail", "action": "acknowledge"}`, http.StatusInternalServerError},
+		{"POST", "secret", `{"dedup_key": "avamon-target-7-incident-1", "action": "acknowledge", "by": "alice"}`, http.StatusNoContent},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, "/ack", strings.NewReader(c.body))
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			body, _ := ioutil.ReadAll(rec.Body)
+			t.Errorf("%v with token %q and body %v: status %v (%s), want %v",
+				c.method, c.token, c.body, rec.Code, body, c.code)
+		}
+	}
+
+	if len(handled) != 1 || handled[0].Action != AckActionAcknowledge || handled[0].By != "alice" {
+		t.Errorf("Unexpected handled acks: %+v", handled)
+	}
+
+	// Without a token nothing is accepted.
+	h.Token = ""
+	req := httptest.NewRequest("POST", "/ack", strings.NewReader(cases[len(cases)-1].body))
+	req.Header.Set("Authorization", "Bearer ")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Empty token: status %v, want %v", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,186 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of push servers.
+const (
+	PushNtfy   = "ntfy"
+	PushGotify = "gotify"
+)
+
+// Push sends events to a self-hosted push server, which delivers them to
+// phones: ntfy or Gotify. Priority of the notifications depends on the event
+// and opening them opens the target's URL.
+type Push struct {
+	// One of Push* constants.
+	Kind string
+	// Base URL of the server, e.g. "https://ntfy.example.com".
+	Server string
+	// Where to publish: the topic for ntfy and the application token for
+	// Gotify.
+	Topic string
+	// Topics of particular targets by their IDs, overriding Topic.
+	Topics map[uint]string
+	// Access token for ntfy servers requiring authentication, optional.
+	Token   string
+	Retries int
+	Backoff time.Duration
+	Client  *http.Client
+	// Optional log of delivery attempts.
+	Log DeliveryLog
+}
+
+// NewPush creates a Push notifier with default retry policy and timeout.
+func NewPush(kind, server, topic string) *Push {
+	return &Push{
+		Kind:    kind,
+		Server:  server,
+		Topic:   topic,
+		Retries: DefaultRetries,
+		Backoff: DefaultBackoff,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// pushPriority maps the event to a priority from 1 (lowest) to 5 (highest).
+func pushPriority(e Event) int {
+	switch {
+	case e.Kind() == EventUp:
+		return 2
+	case e.Kind() == EventChanged:
+		return 3
+	case e.Target.Critical:
+		return 5
+	default:
+		return 4
+	}
+}
+
+// gotifyPriorities maps priorities of pushPriority to Gotify's scale of 0-10.
+var gotifyPriorities = map[int]int{1: 1, 2: 2, 3: 5, 4: 8, 5: 10}
+
+func pushMessage(e Event) string {
+	var lines []string
+	lines = append(lines, e.Target.URL)
+	if e.Status.Err != nil {
+		lines = append(lines, "Error: "+e.Status.Err.Error())
+	}
+	if e.Status.HTTPStatusCode != 0 {
+		lines = append(lines, fmt.Sprintf("HTTP code: %v", e.Status.HTTPStatusCode))
+	}
+	if d := e.Duration(); d > 0 {
+		lines = append(lines, "Duration: "+d.Round(time.Second).String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// topic returns the topic of the target.
+func (p *Push) topic(targetID uint) string {
+	if topic, ok := p.Topics[targetID]; ok {
+		return topic
+	}
+	return p.Topic
+}
+
+// newRequest builds the request publishing the event. It also returns
+// the destination for the delivery log, which doesn't reveal Gotify's token.
+func (p *Push) newRequest(e Event) (*http.Request, string, error) {
+	server := strings.TrimSuffix(p.Server, "/")
+	topic := p.topic(e.Target.ID)
+
+	switch p.Kind {
+	case PushNtfy:
+		dest := server + "/" + url.PathEscape(topic)
+		req, err := http.NewRequest("POST", dest, strings.NewReader(pushMessage(e)))
+		if err != nil {
+			return nil, dest, err
+		}
+		// Header values must be ASCII, so non-ASCII titles are encoded
+		// as RFC 2047, which ntfy decodes.
+		req.Header.Set("Title", mime.BEncoding.Encode("utf-8", slackHeadline(e)))
+		req.Header.Set("Priority", strconv.Itoa(pushPriority(e)))
+		req.Header.Set("Click", e.Target.URL)
+		if e.Kind() == EventUp {
+			req.Header.Set("Tags", "white_check_mark")
+		} else {
+			req.Header.Set("Tags", "rotating_light")
+		}
+		if p.Token != "" {
+			req.Header.Set("Authorization", "Bearer "+p.Token)
+		}
+		return req, dest, nil
+	case PushGotify:
+		dest := server + "/message"
+		body, err := json.Marshal(map[string]interface{}{
+			"title":    slackHeadline(e),
+			"message":  pushMessage(e),
+			"priority": gotifyPriorities[pushPriority(e)],
+			"extras": map[string]interface{}{
+				"client::notification": map[string]interface{}{
+					"click": map[string]string{"url": e.Target.URL},
+				},
+			},
+		})
+		if err != nil {
+			return nil, dest, err
+		}
+		req, err := http.NewRequest("POST", dest, bytes.NewReader(body))
+		if err != nil {
+			return nil, dest, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Gotify-Key", topic)
+		return req, dest, nil
+	}
+	return nil, server, fmt.Errorf("Unknown push server kind %q", p.Kind)
+}
+
+// Notify implements Notifier.
+func (p *Push) Notify(e Event) error {
+	return retry(p.Retries, p.Backoff, func(attempt int) error {
+		return p.post(e, attempt)
+	})
+}
+
+func (p *Push) post(e Event, attempt int) error {
+	req, dest, err := p.newRequest(e)
+	if err != nil {
+		err = permanentError{err}
+	} else {
+		err = p.do(req)
+	}
+	logDelivery(p.Log, Delivery{
+		Notifier:    p.Kind,
+		Destination: dest,
+		IncidentID:  e.IncidentID,
+		TargetID:    e.Target.ID,
+		Attempt:     attempt,
+		Time:        time.Now(),
+		Err:         err,
+	})
+	return err
+}
+
+func (p *Push) do(req *http.Request) error {
+	req.Header.Set("User-Agent", "avamon-bot")
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
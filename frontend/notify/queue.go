@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Queue delivers events to notifiers in background. Events of a target are
+// delivered to a notifier one at a time in the order they were pushed, so
+// that a retried failure is never overtaken by the following recovery.
+// Events of different targets or to different notifiers don't wait for each
+// other. Failures are only logged. The zero Queue is ready to use.
+type Queue struct {
+	mu sync.Mutex
+	// Events waiting for delivery. A key is present while a goroutine
+	// delivers its events.
+	pending map[queueKey][]Event
+}
+
+type queueKey struct {
+	notifier Notifier
+	targetID uint
+}
+
+// Push queues the event for delivery to the notifier.
+func (q *Queue) Push(n Notifier, e Event) {
+	key := queueKey{n, e.Target.ID}
+
+	q.mu.Lock()
+	if q.pending == nil {
+		q.pending = map[queueKey][]Event{}
+	}
+	events, running := q.pending[key]
+	q.pending[key] = append(events, e)
+	q.mu.Unlock()
+
+	if !running {
+		go q.deliver(key)
+	}
+}
+
+func (q *Queue) deliver(key queueKey) {
+	for {
+		q.mu.Lock()
+		events := q.pending[key]
+		if len(events) == 0 {
+			delete(q.pending, key)
+			q.mu.Unlock()
+			return
+		}
+		e := events[0]
+		q.pending[key] = events[1:]
+		q.mu.Unlock()
+
+		if err := key.notifier.Notify(e); err != nil {
+			fmt.Printf("Could not deliver event of target %v: %v\n", e.Target.ID, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// recordingNotifier records the events it's notified of. Events of target 1
+// take a while, as if they were retried.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	events []Event
+	wg     sync.WaitGroup
+}
+
+func (rn *recordingNotifier) Notify(e Event) error {
+	defer rn.wg.Done()
+	if e.Target.ID == 1 && e.Status.Type != monitor.StatusOK {
+		time.Sleep(50 * time.Millisecond)
+	}
+	rn.mu.Lock()
+	defer rn.mu.Unlock()
+	rn.events = append(rn.events, e)
+	return nil
+}
+
+func TestQueueKeepsOrderOfTarget(t *testing.T) {
+	var q Queue
+	rn := &recordingNotifier{}
+	rn.wg.Add(3)
+
+	down := Event{Target: monitor.Target{ID: 1}, Status: monitor.Status{Type: monitor.StatusTimeout}}
+	up := Event{Target: monitor.Target{ID: 1}, Status: monitor.Status{Type: monitor.StatusOK}}
+	other := Event{Target: monitor.Target{ID: 2}, Status: monitor.Status{Type: monitor.StatusOK}}
+	q.Push(rn, down)
+	q.Push(rn, up)
+	q.Push(rn, other)
+	rn.wg.Wait()
+
+	if len(rn.events) != 3 {
+		t.Fatalf("got %v events, want 3", len(rn.events))
+	}
+	if rn.events[0].Target.ID != 2 {
+		t.Errorf("event of target 2 waited for target 1")
+	}
+	if rn.events[1].Kind() != EventDown || rn.events[2].Kind() != EventUp {
+		t.Errorf("got %v, %v; want down, up", rn.events[1].Kind(), rn.events[2].Kind())
+	}
+
+	// The goroutines finish right after the last delivery.
+	for i := 0; ; i++ {
+		q.mu.Lock()
+		left := len(q.pending)
+		q.mu.Unlock()
+		if left == 0 {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("%v queues are left after delivery", left)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
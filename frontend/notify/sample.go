@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// PrintLog prints delivery attempts to stdout. It's used by the commands in
+// bin to try notifiers out.
+type PrintLog struct{}
+
+// LogDelivery implements DeliveryLog.
+func (PrintLog) LogDelivery(d Delivery) error {
+	result := "OK"
+	if d.Err != nil {
+		result = d.Err.Error()
+	}
+	fmt.Printf("Attempt %v to %v: %v\n", d.Attempt, d.Destination, result)
+	return nil
+}
+
+// SampleEvent returns an event of incident 1 of an example target, which
+// goes down or, if up is true, recovers.
+func SampleEvent(up bool) Event {
+	upd := monitor.StatusUpdate{
+		Target: monitor.Target{ID: 1, Title: "Example", URL: "http://example.com", Tags: []string{"test"}},
+		Status: monitor.Status{Type: monitor.StatusTimeout, Err: errors.New("request timed out")},
+	}
+	if up {
+		upd.PrevStatus, upd.PrevOK = upd.Status, true
+		upd.Status = monitor.Status{Type: monitor.StatusOK, ResponseTime: 120 * time.Millisecond, HTTPStatusCode: 200}
+	}
+	return NewEvent(upd, "1", time.Now())
+}
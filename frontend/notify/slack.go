@@ -0,0 +1,292 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SlackAPIURL is the endpoint of Slack's chat.postMessage method.
+const SlackAPIURL = "https://slack.com/api/chat.postMessage"
+
+// DefaultSlackThreadTTL is how long Slack remembers threads of incidents,
+// unless configured otherwise.
+const DefaultSlackThreadTTL = 7 * 24 * time.Hour
+
+// Colors of Slack attachments.
+const (
+	slackColorDown    = "#d50200"
+	slackColorUp      = "#2fa44f"
+	slackColorChanged = "#de9e31"
+)
+
+// Slack posts events as color-coded attachments to Slack or Mattermost.
+//
+// With URL of an incoming webhook it works with both services. Incoming
+// webhooks don't tell the ID of the posted message, so if threading is
+// wanted, set Token and Channel instead: messages are then posted with Slack's
+// chat.postMessage method, and updates of an incident are posted into
+// the thread of its first alert.
+type Slack struct {
+	// URL of an incoming webhook, ignored if Token is set.
+	URL string
+	// Slack bot token with chat:write scope and the channel to post to.
+	Token   string
+	Channel string
+	// Endpoint of chat.postMessage, SlackAPIURL if empty.
+	APIURL  string
+	Retries int
+	Backoff time.Duration
+	Client  *http.Client
+	// Optional log of delivery attempts.
+	Log DeliveryLog
+	// How long updates of an incident are posted into the thread of its first
+	// alert. Threads of incidents, which aren't resolved by then, e.g.
+	// because their targets were deleted, are forgotten. DefaultSlackThreadTTL
+	// if zero.
+	ThreadTTL time.Duration
+
+	mu sync.Mutex
+	// Alerts by incident ID, used to reply in their threads.
+	threads map[string]slackThread
+}
+
+type slackThread struct {
+	ts      string
+	expires time.Time
+}
+
+// NewSlack creates a Slack notifier posting to the incoming webhook with
+// default retry policy and timeout.
+func NewSlack(url string) *Slack {
+	return &Slack{
+		URL:     url,
+		Retries: DefaultRetries,
+		Backoff: DefaultBackoff,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+type slackAttachment struct {
+	Fallback  string       `json:"fallback"`
+	Color     string       `json:"color"`
+	Title     string       `json:"title"`
+	TitleLink string       `json:"title_link,omitempty"`
+	Text      string       `json:"text,omitempty"`
+	Fields    []slackField `json:"fields"`
+	Footer    string       `json:"footer"`
+	Timestamp int64        `json:"ts"`
+}
+
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"`
+	ThreadTS    string            `json:"thread_ts,omitempty"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+// slackHeadline returns a line like "Example is down (Timeout)".
+func slackHeadline(e Event) string {
+	switch e.Kind() {
+	case EventUp:
+		return fmt.Sprintf("%v is up", e.Target.Title)
+	case EventChanged:
+		return fmt.Sprintf("%v is still down (%v)", e.Target.Title, e.Status.Type)
+	default:
+		return fmt.Sprintf("%v is down (%v)", e.Target.Title, e.Status.Type)
+	}
+}
+
+func newSlackMessage(e Event) slackMessage {
+	headline := slackHeadline(e)
+	att := slackAttachment{
+		Fallback:  headline,
+		Title:     headline,
+		TitleLink: e.Target.URL,
+		Footer:    "avamon-bot",
+		Timestamp: e.Time.Unix(),
+	}
+	switch e.Kind() {
+	case EventUp:
+		att.Color = slackColorUp
+	case EventChanged:
+		att.Color = slackColorChanged
+	default:
+		att.Color = slackColorDown
+	}
+	if e.Status.Err != nil {
+		att.Text = e.Status.Err.Error()
+	}
+
+	att.Fields = append(att.Fields, slackField{Title: "URL", Value: e.Target.URL})
+	att.Fields = append(att.Fields, slackField{
+		Title: "Response time",
+		Value: e.Status.ResponseTime.Round(time.Millisecond).String(),
+		Short: true,
+	})
+	if e.Status.HTTPStatusCode != 0 {
+		att.Fields = append(att.Fields, slackField{
+			Title: "HTTP code",
+			Value: strconv.Itoa(e.Status.HTTPStatusCode),
+			Short: true,
+		})
+	}
+	if d := e.Duration(); d > 0 {
+		title := "Down for"
+		if e.Kind() == EventUp {
+			title = "Outage duration"
+		}
+		att.Fields = append(att.Fields, slackField{
+			Title: title,
+			Value: d.Round(time.Second).String(),
+			Short: true,
+		})
+	}
+
+	return slackMessage{Text: headline, Attachments: []slackAttachment{att}}
+}
+
+// Notify implements Notifier.
+func (s *Slack) Notify(e Event) error {
+	msg := newSlackMessage(e)
+	destination := s.URL
+	if s.Token != "" {
+		msg.Channel = s.Channel
+		destination = s.Channel
+		msg.ThreadTS = s.thread(e.IncidentID)
+	}
+
+	var ts string
+	err := retry(s.Retries, s.Backoff, func(attempt int) error {
+		var err error
+		ts, err = s.post(msg)
+		logDelivery(s.Log, Delivery{
+			Notifier:    "slack",
+			Destination: destination,
+			IncidentID:  e.IncidentID,
+			TargetID:    e.Target.ID,
+			Attempt:     attempt,
+			Time:        time.Now(),
+			Err:         err,
+		})
+		return err
+	})
+	// The incident is over even if the recovery couldn't be posted.
+	if e.IncidentID != "" && e.Kind() == EventUp {
+		s.setThread(e.IncidentID, "")
+	}
+	if err != nil {
+		return err
+	}
+	if e.IncidentID != "" && e.Kind() != EventUp && msg.ThreadTS == "" && ts != "" {
+		s.setThread(e.IncidentID, ts)
+	}
+	return nil
+}
+
+func (s *Slack) thread(incidentID string) string {
+	if incidentID == "" {
+		return ""
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	thread, ok := s.threads[incidentID]
+	if !ok || time.Now().After(thread.expires) {
+		return ""
+	}
+	return thread.ts
+}
+
+// setThread remembers the alert of the incident, or forgets it if ts is
+// empty. Expired threads are forgotten as well.
+func (s *Slack) setThread(incidentID, ts string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for id, thread := range s.threads {
+		if now.After(thread.expires) {
+			delete(s.threads, id)
+		}
+	}
+	if ts == "" {
+		delete(s.threads, incidentID)
+		return
+	}
+	if s.threads == nil {
+		s.threads = map[string]slackThread{}
+	}
+	ttl := s.ThreadTTL
+	if ttl == 0 {
+		ttl = DefaultSlackThreadTTL
+	}
+	s.threads[incidentID] = slackThread{ts: ts, expires: now.Add(ttl)}
+}
+
+// post sends the message and returns its timestamp, if the API tells it.
+func (s *Slack) post(msg slackMessage) (string, error) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return "", permanentError{err}
+	}
+	url := s.URL
+	if s.Token != "" {
+		url = s.APIURL
+		if url == "" {
+			url = SlackAPIURL
+		}
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return "", permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "avamon-bot")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err := checkResponse(resp); err != nil {
+		return "", err
+	}
+	if s.Token == "" {
+		return "", nil
+	}
+
+	// The API responds with 200 even on errors.
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if !result.OK {
+		err := errors.New(result.Error)
+		if result.Error == "ratelimited" || result.Error == "internal_error" {
+			return "", err
+		}
+		return "", permanentError{err}
+	}
+	return result.TS, nil
+}
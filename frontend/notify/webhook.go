@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of webhook requests.
+const (
+	WebhookEventHeader     = "X-Avamon-Event"
+	WebhookTimestampHeader = "X-Avamon-Timestamp"
+	WebhookSignatureHeader = "X-Avamon-Signature"
+)
+
+// Webhook POSTs events as JSON (see Payload) to a URL.
+type Webhook struct {
+	URL string
+	// If not empty, requests are signed with HMAC-SHA256 using the secret.
+	// See Sign.
+	Secret string
+	// Number of retries after a failed attempt and the delay before the first
+	// retry, which doubles on every next one. Requests are not retried if
+	// the server responds with 4xx status other than 408 and 429.
+	Retries int
+	Backoff time.Duration
+	Client  *http.Client
+	// Optional log of delivery attempts.
+	Log DeliveryLog
+}
+
+// NewWebhook creates a Webhook with default retry policy and timeout.
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		URL:     url,
+		Secret:  secret,
+		Retries: DefaultRetries,
+		Backoff: DefaultBackoff,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Sign computes the signature of a webhook request: hex-encoded HMAC-SHA256
+// of the timestamp header's value, a dot and the body, prefixed with
+// "sha256=". Receivers should compute it the same way, compare it to
+// the signature header and check the timestamp to prevent replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	io.WriteString(mac, timestamp+".")
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(e Event) error {
+	body, err := json.Marshal(e.Payload())
+	if err != nil {
+		return err
+	}
+
+	return retry(w.Retries, w.Backoff, func(attempt int) error {
+		err := w.post(e, body)
+		logDelivery(w.Log, Delivery{
+			Notifier:    "webhook",
+			Destination: w.URL,
+			IncidentID:  e.IncidentID,
+			TargetID:    e.Target.ID,
+			Attempt:     attempt,
+			Time:        time.Now(),
+			Err:         err,
+		})
+		return err
+	})
+}
+
+func (w *Webhook) post(e Event, body []byte) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "avamon-bot")
+	req.Header.Set(WebhookEventHeader, e.Kind())
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	if w.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, Sign(w.Secret, timestamp, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// checkResponse returns an error if the response status is not 2xx. Errors
+// for 4xx statuses other than 408 and 429 are permanent.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	text, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 256))
+	err := fmt.Errorf("%v: %s", resp.Status, bytes.TrimSpace(text))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return permanentError{err}
+	}
+	return err
+}
@@ -0,0 +1,262 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionCreate  = "create"
+	ActionEdit    = "edit"
+	ActionDelete  = "delete"
+	ActionPause   = "pause"
+	ActionResume  = "resume"
+	ActionRestore = "restore"
+)
+
+var auditActionVerbs = map[string]string{
+	ActionCreate:  "added",
+	ActionEdit:    "edited",
+	ActionDelete:  "deleted",
+	ActionPause:   "paused",
+	ActionResume:  "resumed",
+	ActionRestore: "restored",
+}
+
+// newAuditEntry describes the change of the target in the chat. Either of
+// before and after may be nil.
+func newAuditEntry(chatID int64, action string, before, after *Record) AuditEntry {
+	entry := AuditEntry{
+		ChatID: chatID,
+		Action: action,
+	}
+	for _, pair := range []struct {
+		rec *Record
+		dst *string
+	}{{before, &entry.Before}, {after, &entry.After}} {
+		if pair.rec == nil {
+			continue
+		}
+		entry.TargetID = pair.rec.ID
+		bs, err := json.Marshal(pair.rec)
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+		*pair.dst = string(bs)
+	}
+	return entry
+}
+
+// audit records the change of the target made by the author of the message.
+// Either of before and after may be nil. Errors are only logged, so that
+// a failing audit log does not prevent users from managing targets.
+func (b *Bot) audit(msg *tgbotapi.Message, action string, before, after *Record) {
+	entry := newAuditEntry(msg.Chat.ID, action, before, after)
+	if msg.From != nil {
+		entry.UserID = msg.From.ID
+		entry.Username = msg.From.UserName
+	}
+	if err := b.DB.AddAuditEntry(entry); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func decodeAuditRecord(s string) *Record {
+	if s == "" {
+		return nil
+	}
+	var rec Record
+	if err := json.Unmarshal([]byte(s), &rec); err != nil {
+		return nil
+	}
+	return &rec
+}
+
+func formatAuditEntry(lang string, entry AuditEntry, loc *time.Location) string {
+	var user string
+	switch {
+	case entry.UserID == 0 && entry.Username != "":
+		// Not a Telegram user, e.g. the command line import.
+		user = entry.Username
+	case entry.Username != "":
+		user = "@" + entry.Username
+	default:
+		user = strconv.Itoa(entry.UserID)
+	}
+
+	verb, ok := auditActionVerbs[entry.Action]
+	if !ok {
+		verb = entry.Action
+	}
+	verb = translate(lang, verb)
+
+	before := decodeAuditRecord(entry.Before)
+	after := decodeAuditRecord(entry.After)
+
+	var title string
+	switch {
+	case after != nil:
+		title = after.Title
+	case before != nil:
+		title = before.Title
+	}
+
+	line := fmt.Sprintf(
+		"%v %v %v <b>%v</b> %v",
+		formatTime(lang, entry.CreatedAt.In(loc), "2006-01-02 15:04"),
+		replaceHTML(user), verb, entry.TargetID, replaceHTML(title))
+
+	if before != nil && after != nil {
+		var changes []string
+		if before.Title != after.Title {
+			changes = append(changes, translate(
+				lang, "title: %v → %v", replaceHTML(before.Title), replaceHTML(after.Title)))
+		}
+		if before.URL != after.URL {
+			changes = append(changes, translate(
+				lang, "url: %v → %v", replaceHTML(before.URL), replaceHTML(after.URL)))
+		}
+		if before.Tags != after.Tags {
+			changes = append(changes, translate(
+				lang, "tags: %v → %v", formatTags(before.TagList()), formatTags(after.TagList())))
+		}
+		if before.Template != after.Template {
+			changes = append(changes, translate(lang, "template changed"))
+		}
+		if before.Critical != after.Critical {
+			changes = append(changes, translate(
+				lang, "critical: %v → %v", formatBool(before.Critical), formatBool(after.Critical)))
+		}
+		if len(changes) > 0 {
+			line += "\n    " + strings.Join(changes, "\n    ")
+		}
+	}
+
+	return line
+}
+
+func (b *Bot) sendAuditLog(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	entries, err := b.DB.GetAuditEntries(message.Chat.ID, 20)
+	if err != nil {
+		b.SendMessage(
+			message.Chat.ID,
+			translate(
+				lang,
+				"Error while retrieving the audit log, please contact the administrator: %v",
+				b.AdminNickname))
+		return
+	}
+	if len(entries) == 0 {
+		b.SendMessage(message.Chat.ID, translate(lang, "No changes were made to the targets yet"))
+		return
+	}
+
+	settings, err := b.getChatSettings(message.Chat.ID)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	var lines []string
+	for _, entry := range entries {
+		lines = append(lines, formatAuditEntry(lang, entry, settings.Location()))
+	}
+	b.SendMessage(message.Chat.ID, strings.Join(lines, "\n"))
+}
+
+func (b *Bot) purgeDeletedTargets() {
+	for range time.Tick(time.Hour) {
+		err := b.DB.PurgeDeletedTargets(time.Now().Add(-b.RestoreRetention))
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+type restoreTarget struct {
+	bot *Bot
+}
+
+func (t *restoreTarget) Kind() string { return "restore" }
+
+func (t *restoreTarget) ContinueDialog(stepNumber int, update tgbotapi.Update, bot *tgbotapi.BotAPI) (int, bool) {
+	if stepNumber == 1 {
+		targs, err := t.bot.DB.GetDeletedTargets(
+			update.Message.Chat.ID, time.Now().Add(-t.bot.RestoreRetention))
+		if err != nil {
+			t.bot.SendMessage(
+				update.Message.Chat.ID,
+				t.bot.tr(
+					update.Message.Chat.ID,
+					"Error while retrieving the targets, please contact the administrator: %v",
+					t.bot.AdminNickname))
+			return 0, false
+		}
+		if len(targs) == 0 {
+			t.bot.SendMessage(update.Message.Chat.ID, t.bot.tr(update.Message.Chat.ID, "There are no recently deleted targets"))
+			return 0, false
+		}
+		var targetStrings []string
+		lang := t.bot.chatLanguage(update.Message.Chat.ID)
+		targetStrings = append(targetStrings, translate(
+			lang,
+			"Enter the <b>ID</b> of a target to restore it. Send /cancel if you've changed your mind.")+"\n")
+		for _, target := range targs {
+			targetStrings = append(
+				targetStrings,
+				translate(
+					lang,
+					"<b>%v</b>: <a href=\"%v\">%v</a> (deleted %v)",
+					target.ID,
+					replaceHTML(target.URL),
+					replaceHTML(target.Title),
+					formatTime(lang, *target.DeletedAt, "2006-01-02 15:04"),
+				),
+			)
+		}
+		t.bot.SendDialogMessage(update.Message, strings.Join(targetStrings, "\n"))
+		return 2, true
+	}
+	if stepNumber == 2 {
+		id, err := strconv.Atoi(update.Message.Text)
+		if err != nil {
+			t.bot.SendDialogMessage(update.Message, t.bot.tr(update.Message.Chat.ID, "Invalid ID, please try again"))
+			return 2, true
+		}
+		targs, err := t.bot.DB.GetDeletedTargets(
+			update.Message.Chat.ID, time.Now().Add(-t.bot.RestoreRetention))
+		var found *Record
+		for i := range targs {
+			if targs[i].ID == uint(id) {
+				found = &targs[i]
+			}
+		}
+		if err != nil || found == nil {
+			t.bot.SendMessage(update.Message.Chat.ID, t.bot.tr(update.Message.Chat.ID, "No deleted target with such ID found"))
+			return 0, false
+		}
+		err = t.bot.DB.RestoreTarget(id)
+		if err != nil {
+			t.bot.SendMessage(
+				update.Message.Chat.ID,
+				t.bot.tr(
+					update.Message.Chat.ID,
+					"Error while restoring the target, please contact the administrator: %v",
+					t.bot.AdminNickname))
+			return 0, false
+		}
+		restored := *found
+		restored.DeletedAt = nil
+		t.bot.audit(update.Message, ActionRestore, found, &restored)
+		t.bot.SendMessage(update.Message.Chat.ID, t.bot.tr(update.Message.Chat.ID, "Target was successfully restored!"))
+		return 0, false
+	}
+	return 0, false
+}
@@ -14,12 +14,112 @@ type Config struct {
 		ExpirationTime int
 	}
 	Database struct {
-		Name string
+		Name        string
+		HistoryDays int
 	}
 	Telegram struct {
-		APIKey string
-		Admin  string
-		Debug  bool
+		APIKey         string
+		Admin          string
+		Debug          bool
+		SessionTimeout int
+		Superusers     []int
+		RestoreHours   int
+		Template       string
+	}
+	ChatDefaults struct {
+		Policy         string
+		MuteRecovery   bool
+		SilentRecovery bool
+		HideDetails    bool
+		Timezone       string
+		Verbosity      string
+		QuietHours     string
+		QuietMode      string
+		Language       string
+	}
+	Check struct {
+		Interval  int
+		Blocklist []string
+	}
+	Security struct {
+		BlockPrivate bool
+		DenyNets     []string
+		AllowNets    []string
+		TrustedChats []int64
+	}
+	Notify struct {
+		Retries  int
+		Backoff  int
+		FirstOK  bool
+		Webhooks []struct {
+			Name   string
+			URL    string
+			Secret string
+		}
+		Slack []struct {
+			Name    string
+			URL     string
+			Token   string
+			Channel string
+		}
+		Push []struct {
+			Name   string
+			Kind   string
+			Server string
+			Topic  string
+			Token  string
+			// Topics by targets' IDs.
+			Topics map[string]string
+		}
+		Exec []struct {
+			Name        string
+			Command     []string
+			Events      []string
+			Timeout     int
+			Concurrency int
+			FollowUp    bool
+		}
+		Matrix struct {
+			Homeserver string
+			Token      string
+			Rooms      []string
+		}
+		Pager struct {
+			Name         string
+			URL          string
+			RoutingKey   string
+			CriticalOnly bool
+			Listen       string
+			Token        string
+		}
+		Email struct {
+			Name     string
+			Host     string
+			Port     int
+			Security string
+			Username string
+			Password string
+			From     string
+			To       []string
+			Domains  []string
+			Batch    int
+		}
+	}
+	Routing struct {
+		Default string
+		Rules   []struct {
+			Name        string
+			Tags        []string
+			Status      []string
+			Severity    []string
+			Hours       string
+			Days        []string
+			Timezone    string
+			TargetChats bool
+			Chats       []int64
+			Notifiers   []string
+			Continue    bool
+		}
 	}
 	Redis struct {
 		Host string
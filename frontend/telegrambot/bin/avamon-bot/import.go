@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yamnikov-oleg/avamon-bot/frontend/telegrambot"
+)
+
+// runImport implements "avamon-bot import" subcommand, which imports targets
+// from a file into a chat without going through the bot.
+func runImport(args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	configPath := flags.String("config", "config.toml", "Path to the config file")
+	chatID := flags.Int64("chat", 0, "ID of the chat to import the targets to")
+	dryRun := flags.Bool("dry-run", false, "Only show what would be imported")
+	flags.Usage = func() {
+		fmt.Println("Usage: avamon-bot import [flags] FILE")
+		fmt.Println()
+		fmt.Println("FILE is an /export file (JSON, CSV or YAML), a CSV monitor list of another")
+		fmt.Println("uptime tool or a Prometheus config with blackbox exporter jobs.")
+		fmt.Println()
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 || *chatID == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	config, err := ReadConfig(*configPath)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	data, err := ioutil.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	connection, err := gorm.Open("sqlite3", config.Database.Name)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	db := &telegrambot.TargetsDB{DB: connection}
+	db.Migrate()
+
+	report, err := db.ImportTargets(*chatID, "cli", flags.Arg(0), data, *dryRun)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	for _, line := range report.Skipped {
+		fmt.Printf("Could not translate: %v\n", line)
+	}
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf(
+		"%v: %v added, %v changed, %v unchanged\n",
+		verb, report.Added, report.Changed, report.Unchanged)
+}
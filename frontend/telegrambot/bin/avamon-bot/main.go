@@ -3,12 +3,15 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/jinzhu/gorm"
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
 	"github.com/yamnikov-oleg/avamon-bot/frontend/telegrambot"
 	"github.com/yamnikov-oleg/avamon-bot/monitor"
 )
@@ -18,9 +21,23 @@ func monitorCreate(b *telegrambot.Bot, config *Config) error {
 	mon.Scheduler.Interval = time.Duration(config.Monitor.Interval) * time.Second
 	mon.Scheduler.ParallelPolls = config.Monitor.MaxParallel
 	mon.Scheduler.Poller.Timeout = time.Duration(config.Monitor.Timeout) * time.Second
-	mon.NotifyFirstOK = config.Monitor.NotifyFirstOK
+	// Whether to notify about first OK statuses is decided per chat by the bot.
+	mon.NotifyFirstOK = true
 	mon.Scheduler.Poller.TimeoutRetries = config.Monitor.TimeoutRetries
 	mon.ExpirationTime = time.Duration(config.Monitor.ExpirationTime) * time.Second
+	mon.History = b.DB
+
+	denyNets := config.Security.DenyNets
+	if config.Security.BlockPrivate {
+		denyNets = append(denyNets, monitor.DefaultDeniedNets...)
+	}
+	if len(denyNets) > 0 {
+		filter, err := monitor.NewIPFilter(config.Security.AllowNets, denyNets)
+		if err != nil {
+			return err
+		}
+		mon.Scheduler.Poller.IPFilter = filter
+	}
 
 	ropts := monitor.RedisOptions{
 		Host:     config.Redis.Host,
@@ -41,6 +58,11 @@ func monitorCreate(b *telegrambot.Bot, config *Config) error {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		runImport(os.Args[2:])
+		return
+	}
+
 	bot := telegrambot.Bot{}
 
 	configPath := flag.String("config", "config.toml", "Path to the config file")
@@ -73,9 +95,11 @@ func main() {
 		os.Exit(1)
 	}
 	bot.DB = &telegrambot.TargetsDB{
-		DB: connection,
+		DB:           connection,
+		TrustedChats: config.Security.TrustedChats,
 	}
 	bot.DB.Migrate()
+	bot.HistoryRetention = time.Duration(config.Database.HistoryDays) * 24 * time.Hour
 
 	bot.TgBot, err = tgbotapi.NewBotAPI(config.Telegram.APIKey)
 	if err != nil {
@@ -84,7 +108,198 @@ func main() {
 	}
 	bot.TgBot.Debug = config.Telegram.Debug
 	bot.AdminNickname = config.Telegram.Admin
+	bot.SessionTimeout = time.Duration(config.Telegram.SessionTimeout) * time.Second
+	bot.Superusers = config.Telegram.Superusers
+	bot.RestoreRetention = time.Duration(config.Telegram.RestoreHours) * time.Hour
+	bot.DefaultSettings = telegrambot.ChatSettings{
+		ManagePolicy:   config.ChatDefaults.Policy,
+		NotifyFirstOK:  config.Monitor.NotifyFirstOK,
+		MuteRecovery:   config.ChatDefaults.MuteRecovery,
+		SilentRecovery: config.ChatDefaults.SilentRecovery,
+		HideDetails:    config.ChatDefaults.HideDetails,
+		Timezone:       config.ChatDefaults.Timezone,
+		Verbosity:      config.ChatDefaults.Verbosity,
+		QuietHours:     config.ChatDefaults.QuietHours,
+		QuietMode:      config.ChatDefaults.QuietMode,
+		Language:       config.ChatDefaults.Language,
+	}
+
+	bot.NamedNotifiers = map[string]notify.Notifier{}
+	addNotifier := func(name string, n notify.Notifier) {
+		bot.Notifiers = append(bot.Notifiers, n)
+		if name == "" {
+			return
+		}
+		if bot.NamedNotifiers[name] != nil {
+			fmt.Printf("Notifier name %q is used twice\n", name)
+			os.Exit(1)
+		}
+		bot.NamedNotifiers[name] = n
+	}
+	bot.NotifyFirstOK = config.Notify.FirstOK
+	bot.NotifyRetries = config.Notify.Retries
+	bot.NotifyBackoff = time.Duration(config.Notify.Backoff) * time.Second
+	for _, webhookConfig := range config.Notify.Webhooks {
+		webhook := notify.NewWebhook(webhookConfig.URL, webhookConfig.Secret)
+		webhook.Retries = config.Notify.Retries
+		webhook.Backoff = bot.NotifyBackoff
+		webhook.Log = bot.DB
+		addNotifier(webhookConfig.Name, webhook)
+	}
+	for _, slackConfig := range config.Notify.Slack {
+		slack := notify.NewSlack(slackConfig.URL)
+		slack.Token = slackConfig.Token
+		slack.Channel = slackConfig.Channel
+		slack.Retries = config.Notify.Retries
+		slack.Backoff = bot.NotifyBackoff
+		slack.Log = bot.DB
+		addNotifier(slackConfig.Name, slack)
+	}
+	for _, pushConfig := range config.Notify.Push {
+		if pushConfig.Kind != notify.PushNtfy && pushConfig.Kind != notify.PushGotify {
+			fmt.Printf("Unknown push server kind %q, use ntfy or gotify\n", pushConfig.Kind)
+			os.Exit(1)
+		}
+		push := notify.NewPush(pushConfig.Kind, pushConfig.Server, pushConfig.Topic)
+		push.Token = pushConfig.Token
+		push.Topics = map[uint]string{}
+		for id, topic := range pushConfig.Topics {
+			targetID, err := strconv.ParseUint(id, 10, 0)
+			if err != nil {
+				fmt.Printf("Invalid target ID %q in push topics\n", id)
+				os.Exit(1)
+			}
+			push.Topics[uint(targetID)] = topic
+		}
+		push.Retries = config.Notify.Retries
+		push.Backoff = bot.NotifyBackoff
+		push.Log = bot.DB
+		addNotifier(pushConfig.Name, push)
+	}
+	// Hooks are only configured here, chats can't add commands to run.
+	for _, execConfig := range config.Notify.Exec {
+		if len(execConfig.Command) == 0 {
+			fmt.Println("notify.exec.command must not be empty")
+			os.Exit(1)
+		}
+		hook := notify.NewExec(execConfig.Command)
+		hook.Events = execConfig.Events
+		if execConfig.Timeout != 0 {
+			hook.Timeout = time.Duration(execConfig.Timeout) * time.Second
+		}
+		if execConfig.Concurrency != 0 {
+			hook.Concurrency = execConfig.Concurrency
+		}
+		followUp := execConfig.FollowUp
+		hook.OnResult = func(res notify.ExecResult) {
+			bot.RecordHookRun(res, followUp)
+		}
+		hook.Log = bot.DB
+		addNotifier(execConfig.Name, hook)
+	}
+	bot.MatrixHomeserver = config.Notify.Matrix.Homeserver
+	bot.MatrixToken = config.Notify.Matrix.Token
+	for _, room := range config.Notify.Matrix.Rooms {
+		matrix := notify.NewMatrix(bot.MatrixHomeserver, bot.MatrixToken, room)
+		matrix.Retries = config.Notify.Retries
+		matrix.Backoff = bot.NotifyBackoff
+		matrix.Log = bot.DB
+		addNotifier("", matrix)
+	}
+	bot.PagerURL = config.Notify.Pager.URL
+	if config.Notify.Pager.RoutingKey != "" {
+		pager := notify.NewPager(bot.PagerURL, config.Notify.Pager.RoutingKey)
+		pager.CriticalOnly = config.Notify.Pager.CriticalOnly
+		pager.Retries = config.Notify.Retries
+		pager.Backoff = bot.NotifyBackoff
+		pager.Log = bot.DB
+		addNotifier(config.Notify.Pager.Name, pager)
+	}
+	if config.Notify.Pager.Listen != "" {
+		if config.Notify.Pager.Token == "" {
+			fmt.Println("notify.pager.token must be set to receive acknowledgements")
+			os.Exit(1)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/ack", bot.AckHandler(config.Notify.Pager.Token))
+		go func() {
+			err := http.ListenAndServe(config.Notify.Pager.Listen, mux)
+			fmt.Println(err)
+		}()
+	}
+	if emailConfig := config.Notify.Email; emailConfig.Host != "" {
+		server := notify.SMTPServer{
+			Host:     emailConfig.Host,
+			Port:     emailConfig.Port,
+			Security: emailConfig.Security,
+			Username: emailConfig.Username,
+			Password: emailConfig.Password,
+			From:     emailConfig.From,
+		}
+		if err := server.Validate(); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		bot.SMTPServer = &server
+		bot.EmailDomains = emailConfig.Domains
+		bot.EmailBatchDelay = time.Duration(emailConfig.Batch) * time.Second
+		if len(emailConfig.To) > 0 {
+			email := notify.NewEmail(server, emailConfig.To)
+			email.BatchDelay = bot.EmailBatchDelay
+			if email.BatchDelay == 0 {
+				email.BatchDelay = notify.DefaultBatchDelay
+			}
+			email.Retries = config.Notify.Retries
+			email.Backoff = bot.NotifyBackoff
+			email.Log = bot.DB
+			addNotifier(emailConfig.Name, email)
+		}
+	}
+
+	var rules []telegrambot.RoutingRule
+	for _, ruleConfig := range config.Routing.Rules {
+		rules = append(rules, telegrambot.RoutingRule{
+			Name:        ruleConfig.Name,
+			Tags:        ruleConfig.Tags,
+			Status:      ruleConfig.Status,
+			Severity:    ruleConfig.Severity,
+			Hours:       ruleConfig.Hours,
+			Days:        ruleConfig.Days,
+			Timezone:    ruleConfig.Timezone,
+			TargetChats: ruleConfig.TargetChats,
+			Chats:       ruleConfig.Chats,
+			Notifiers:   ruleConfig.Notifiers,
+			Continue:    ruleConfig.Continue,
+		})
+	}
+	switch config.Routing.Default {
+	case "", "deliver":
+	case "drop":
+		bot.DropUnrouted = true
+	default:
+		fmt.Printf("Unknown routing default %q, use deliver or drop\n", config.Routing.Default)
+		os.Exit(1)
+	}
+	err = bot.SetRoutingRules(rules)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	err = bot.SetNotificationTemplate(config.Telegram.Template)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	bot.CheckInterval = time.Duration(config.Check.Interval) * time.Second
+	err = bot.SetCheckBlocklist(config.Check.Blocklist)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 
+	bot.Init()
 	err = monitorCreate(&bot, config)
 	if err != nil {
 		fmt.Println(err)
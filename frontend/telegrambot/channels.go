@@ -0,0 +1,597 @@
+package telegrambot
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// channelKind describes a kind of notification channels, which chats can
+// add with /channels.
+type channelKind struct {
+	// Arguments of "/channels add KIND ...".
+	Usage string
+	// Parse validates the arguments of the message adding the channel and
+	// fills the channel's fields.
+	Parse func(b *Bot, msg *tgbotapi.Message, ch *NotificationChannel, args []string) error
+	// New constructs the channel's notifier. Notifiers are cached until
+	// the channel is deleted, so they can keep state between events.
+	New func(b *Bot, ch NotificationChannel) notify.Notifier
+}
+
+var channelKinds = map[string]channelKind{
+	"webhook": {
+		Usage: "URL [secret]",
+		Parse: func(b *Bot, msg *tgbotapi.Message, ch *NotificationChannel, args []string) error {
+			if len(args) < 1 || len(args) > 2 {
+				return errorf("Expected URL and optional secret")
+			}
+			if err := validateChannelURL(args[0]); err != nil {
+				return err
+			}
+			ch.Destination = args[0]
+			if len(args) == 2 {
+				ch.Secret = args[1]
+			}
+			return nil
+		},
+		New: func(b *Bot, ch NotificationChannel) notify.Notifier {
+			webhook := notify.NewWebhook(ch.Destination, ch.Secret)
+			webhook.Retries = b.NotifyRetries
+			webhook.Backoff = b.NotifyBackoff
+			webhook.Client = b.channelHTTPClient(ch.ChatID)
+			webhook.Log = chatDeliveryLog{b.DB, ch.ChatID}
+			return webhook
+		},
+	},
+	"slack": {
+		Usage: "WEBHOOK-URL",
+		Parse: func(b *Bot, msg *tgbotapi.Message, ch *NotificationChannel, args []string) error {
+			if len(args) != 1 {
+				return errorf("Expected URL of an incoming webhook of Slack or Mattermost")
+			}
+			if err := validateChannelURL(args[0]); err != nil {
+				return err
+			}
+			ch.Destination = args[0]
+			return nil
+		},
+		New: func(b *Bot, ch NotificationChannel) notify.Notifier {
+			slack := notify.NewSlack(ch.Destination)
+			slack.Retries = b.NotifyRetries
+			slack.Backoff = b.NotifyBackoff
+			slack.Client = b.channelHTTPClient(ch.ChatID)
+			slack.Log = chatDeliveryLog{b.DB, ch.ChatID}
+			return slack
+		},
+	},
+	"matrix": {
+		Usage: "ROOM-ID",
+		Parse: func(b *Bot, msg *tgbotapi.Message, ch *NotificationChannel, args []string) error {
+			if b.MatrixHomeserver == "" {
+				return errorf("Matrix is not configured on this bot")
+			}
+			// The account may be in rooms of other chats, so rooms are only
+			// assigned to chats by superusers.
+			if msg.From == nil || !b.isSuperuser(msg.From.ID) {
+				return errorf("Only the bot's superusers can add Matrix channels")
+			}
+			if len(args) != 1 || !strings.HasPrefix(args[0], "!") || !strings.Contains(args[0], ":") {
+				return errorf("Expected room ID like !abcdef:matrix.org")
+			}
+			ch.Destination = args[0]
+			return nil
+		},
+		New: func(b *Bot, ch NotificationChannel) notify.Notifier {
+			if b.MatrixHomeserver == "" {
+				return nil
+			}
+			matrix := notify.NewMatrix(b.MatrixHomeserver, b.MatrixToken, ch.Destination)
+			matrix.Retries = b.NotifyRetries
+			matrix.Backoff = b.NotifyBackoff
+			matrix.Log = chatDeliveryLog{b.DB, ch.ChatID}
+			return matrix
+		},
+	},
+	"pager": {
+		Usage: "ROUTING-KEY",
+		Parse: func(b *Bot, msg *tgbotapi.Message, ch *NotificationChannel, args []string) error {
+			if len(args) != 1 {
+				return errorf("Expected integration key of the service to page")
+			}
+			ch.Destination = b.PagerURL
+			if ch.Destination == "" {
+				ch.Destination = notify.PagerDutyEventsURL
+			}
+			ch.Secret = args[0]
+			return nil
+		},
+		New: func(b *Bot, ch NotificationChannel) notify.Notifier {
+			pager := notify.NewPager(ch.Destination, ch.Secret)
+			pager.Retries = b.NotifyRetries
+			pager.Backoff = b.NotifyBackoff
+			pager.Client = b.channelHTTPClient(ch.ChatID)
+			pager.Log = chatDeliveryLog{b.DB, ch.ChatID}
+			return pager
+		},
+	},
+	"ntfy": {
+		Usage: "URL/TOPIC [token]",
+		Parse: func(b *Bot, msg *tgbotapi.Message, ch *NotificationChannel, args []string) error {
+			if len(args) < 1 || len(args) > 2 {
+				return errorf("Expected URL of the topic and optional access token")
+			}
+			if err := validateChannelURL(args[0]); err != nil {
+				return err
+			}
+			u, _ := url.Parse(args[0])
+			if strings.Trim(u.Path, "/") == "" {
+				return errorf("The URL must contain the topic, e.g. https://ntfy.sh/alerts")
+			}
+			ch.Destination = strings.TrimSuffix(args[0], "/")
+			if len(args) == 2 {
+				ch.Secret = args[1]
+			}
+			return nil
+		},
+		New: func(b *Bot, ch NotificationChannel) notify.Notifier {
+			slash := strings.LastIndex(ch.Destination, "/")
+			push := notify.NewPush(notify.PushNtfy, ch.Destination[:slash], ch.Destination[slash+1:])
+			push.Token = ch.Secret
+			push.Retries = b.NotifyRetries
+			push.Backoff = b.NotifyBackoff
+			push.Client = b.channelHTTPClient(ch.ChatID)
+			push.Log = chatDeliveryLog{b.DB, ch.ChatID}
+			return push
+		},
+	},
+	"gotify": {
+		Usage: "URL APP-TOKEN",
+		Parse: func(b *Bot, msg *tgbotapi.Message, ch *NotificationChannel, args []string) error {
+			if len(args) != 2 {
+				return errorf("Expected URL of the server and application token")
+			}
+			if err := validateChannelURL(args[0]); err != nil {
+				return err
+			}
+			ch.Destination = args[0]
+			ch.Secret = args[1]
+			return nil
+		},
+		New: func(b *Bot, ch NotificationChannel) notify.Notifier {
+			push := notify.NewPush(notify.PushGotify, ch.Destination, ch.Secret)
+			push.Retries = b.NotifyRetries
+			push.Backoff = b.NotifyBackoff
+			push.Client = b.channelHTTPClient(ch.ChatID)
+			push.Log = chatDeliveryLog{b.DB, ch.ChatID}
+			return push
+		},
+	},
+	"email": {
+		Usage: "ADDRESS[,ADDRESS...]",
+		Parse: func(b *Bot, msg *tgbotapi.Message, ch *NotificationChannel, args []string) error {
+			if b.SMTPServer == nil {
+				return errorf("Email is not configured on this bot")
+			}
+			if len(args) != 1 {
+				return errorf("Expected comma-separated addresses")
+			}
+			addrs, err := parseEmailAddresses(args[0])
+			if err != nil {
+				return err
+			}
+			if msg.From == nil || !b.isSuperuser(msg.From.ID) {
+				if err := b.checkEmailDomains(addrs); err != nil {
+					return err
+				}
+			}
+			ch.Destination = strings.Join(addrs, ",")
+			return nil
+		},
+		New: func(b *Bot, ch NotificationChannel) notify.Notifier {
+			if b.SMTPServer == nil {
+				return nil
+			}
+			email := notify.NewEmail(*b.SMTPServer, strings.Split(ch.Destination, ","))
+			email.BatchDelay = b.EmailBatchDelay
+			email.Retries = b.NotifyRetries
+			email.Backoff = b.NotifyBackoff
+			email.Log = chatDeliveryLog{b.DB, ch.ChatID}
+			if settings, err := b.getChatSettings(ch.ChatID); err == nil {
+				email.Location = settings.Location()
+			}
+			return email
+		},
+	},
+}
+
+// checkEmailDomains checks that the addresses belong to EmailDomains or their
+// subdomains, so that chats can't send email anywhere through the bot's SMTP
+// account.
+func (b *Bot) checkEmailDomains(addrs []string) error {
+	if len(b.EmailDomains) == 0 {
+		return errorf("Only the bot's superusers can add email channels")
+	}
+	for _, addr := range addrs {
+		domain := strings.ToLower(addr[strings.LastIndex(addr, "@")+1:])
+		allowed := false
+		for _, d := range b.EmailDomains {
+			d = strings.ToLower(d)
+			allowed = allowed || domain == d || strings.HasSuffix(domain, "."+d)
+		}
+		if !allowed {
+			return errorf(
+				"%v is not in %v, only the bot's superusers can add other addresses",
+				addr, strings.Join(b.EmailDomains, ", "))
+		}
+	}
+	return nil
+}
+
+// parseEmailAddresses parses comma-separated email addresses and returns them
+// without display names.
+func parseEmailAddresses(list string) ([]string, error) {
+	var addrs []string
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		addr, err := mail.ParseAddress(part)
+		if err != nil {
+			return nil, errorf("Invalid address %v", part)
+		}
+		addrs = append(addrs, addr.Address)
+	}
+	if len(addrs) == 0 {
+		return nil, errorf("Expected comma-separated addresses")
+	}
+	return addrs, nil
+}
+
+// channelNotifierCache keeps notifiers of channels between events.
+type channelNotifierCache struct {
+	mu        sync.Mutex
+	notifiers map[uint]notify.Notifier
+}
+
+// get returns the channel's cached notifier, constructing it if needed.
+// It returns nil if the channel's kind is unknown.
+func (c *channelNotifierCache) get(b *Bot, ch NotificationChannel) notify.Notifier {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n, ok := c.notifiers[ch.ID]; ok {
+		return n
+	}
+	kind, ok := channelKinds[ch.Kind]
+	if !ok {
+		return nil
+	}
+	n := kind.New(b, ch)
+	if n == nil {
+		return nil
+	}
+	if c.notifiers == nil {
+		c.notifiers = map[uint]notify.Notifier{}
+	}
+	c.notifiers[ch.ID] = n
+	return n
+}
+
+func (c *channelNotifierCache) forget(id uint) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.notifiers, id)
+}
+
+func validateChannelURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errorf("Invalid URL, it must start with http:// or https://")
+	}
+	return nil
+}
+
+// channelHTTPClient returns a client for requests of the chat's channels. It
+// is subject to the poller's IP filter, unless the chat is trusted, so that
+// channels can't be used to reach internal addresses.
+func (b *Bot) channelHTTPClient(chatID int64) *http.Client {
+	client := &http.Client{Timeout: 10 * time.Second}
+	if b.Monitor != nil {
+		filter := b.Monitor.Scheduler.Poller.IPFilter
+		if filter != nil && !b.DB.isTrustedChat(chatID) {
+			client.Transport = filter.Transport(client.Timeout)
+		}
+	}
+	return client
+}
+
+// chatDeliveryLog logs deliveries of a chat's channels.
+type chatDeliveryLog struct {
+	db     *TargetsDB
+	chatID int64
+}
+
+func (l chatDeliveryLog) LogDelivery(d notify.Delivery) error {
+	return l.db.addDelivery(l.chatID, d)
+}
+
+// trackIncident opens an incident when the target fails and resolves it when
+// the target is OK again. It returns the incident the update belongs to, or
+// nil.
+func (b *Bot) trackIncident(targetID uint, upd monitor.StatusUpdate, at time.Time) (*Incident, error) {
+	inc, err := b.DB.GetOpenIncident(targetID)
+	if err != nil {
+		return nil, err
+	}
+
+	if upd.Status.Type == monitor.StatusOK {
+		if inc == nil {
+			return nil, nil
+		}
+		return inc, b.DB.ResolveIncident(inc.ID, at)
+	}
+
+	if inc == nil {
+		inc, err = b.DB.OpenIncident(targetID, at)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inc, nil
+}
+
+// newEvent constructs an event of the update, which belongs to the incident.
+func newEvent(upd monitor.StatusUpdate, inc *Incident, at time.Time) notify.Event {
+	if inc == nil {
+		return notify.NewEvent(upd, "", at)
+	}
+	ev := notify.NewEvent(upd, strconv.FormatUint(uint64(inc.ID), 10), at)
+	ev.IncidentStart = inc.StartedAt
+	return ev
+}
+
+// acknowledgeIncident applies an acknowledgement from an incident management
+// system and tells the target's chats about it.
+func (b *Bot) acknowledgeIncident(targetID uint, incidentID string, ack notify.Ack) error {
+	id, err := strconv.ParseUint(incidentID, 10, 0)
+	if err != nil {
+		return notify.ErrUnknownIncident
+	}
+	inc, err := b.DB.GetIncident(targetID, uint(id))
+	if err != nil {
+		return err
+	}
+	if inc == nil {
+		return notify.ErrUnknownIncident
+	}
+	rec, err := b.DB.GetTarget(int(targetID))
+	if err != nil {
+		return notify.ErrUnknownIncident
+	}
+
+	var at *time.Time
+	by := ""
+	if ack.Action == notify.AckActionAcknowledge {
+		now := time.Now()
+		at, by = &now, ack.By
+	}
+	if err := b.DB.AcknowledgeIncident(inc.ID, by, at); err != nil {
+		return err
+	}
+	if inc.ResolvedAt != nil {
+		return nil
+	}
+
+	for _, chatID := range b.targetChats(rec) {
+		lang := b.chatLanguage(chatID)
+		var message string
+		if at == nil {
+			message = translate(lang, "Acknowledgement of the incident of <b>%v</b> was withdrawn", replaceHTML(rec.Title))
+		} else if by != "" {
+			message = translate(lang, "Incident of <b>%v</b> was acknowledged by %v", replaceHTML(rec.Title), replaceHTML(by))
+		} else {
+			message = translate(lang, "Incident of <b>%v</b> was acknowledged", replaceHTML(rec.Title))
+		}
+		b.SendMessage(chatID, message)
+	}
+	return nil
+}
+
+// AckHandler returns the handler of acknowledgements from incident management
+// systems, see notify.AckHandler.
+func (b *Bot) AckHandler(token string) http.Handler {
+	return &notify.AckHandler{Token: token, Handle: b.acknowledgeIncident}
+}
+
+// dispatchEvent delivers the event to the global notifiers and to channels of
+// the chats. Deliveries run in background, so that retries don't hold up
+// the monitor, but in order for every notifier and target.
+func (b *Bot) dispatchEvent(ev notify.Event, chats []int64, notifiers []notify.Notifier) {
+	for _, n := range notifiers {
+		b.deliveries.Push(n, ev)
+	}
+	if len(chats) == 0 {
+		return
+	}
+
+	channels, err := b.DB.GetTargetChannels(chats, ev.Target.ID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, ch := range channels {
+		if n := b.channelNotifiers.get(b, ch); n != nil {
+			b.deliveries.Push(n, ev)
+		}
+	}
+}
+
+func formatChannel(lang string, ch NotificationChannel) string {
+	line := fmt.Sprintf("<b>%v</b>: %v %v", ch.ID, ch.Kind, replaceHTML(ch.Destination))
+	if ch.TargetID != 0 {
+		line += translate(lang, " (target %v only)", ch.TargetID)
+	}
+	if ch.Secret != "" {
+		line += translate(lang, ", with secret")
+	}
+	return line
+}
+
+func channelsUsage(lang string) string {
+	var lines []string
+	lines = append(lines, translate(lang, "Usage:"), translate(lang, "/channels - list the chat's channels"))
+	var names []string
+	for name := range channelKinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf(
+			"/channels add %v %v [target=ID]", name, replaceHTML(channelKinds[name].Usage)))
+	}
+	lines = append(lines,
+		"/channels delete ID",
+		translate(lang, "/channels log - show latest deliveries"))
+	return strings.Join(lines, "\n")
+}
+
+// manageChannels handles /channels command.
+func (b *Bot) manageChannels(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	internalError := func(err error) {
+		fmt.Println(err)
+		b.SendMessage(
+			message.Chat.ID,
+			translate(
+				lang,
+				"Error while managing the channels, please contact the administrator: %v",
+				b.AdminNickname))
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		channels, err := b.DB.GetChatChannels(message.Chat.ID)
+		if err != nil {
+			internalError(err)
+			return
+		}
+		if len(channels) == 0 {
+			b.SendMessage(message.Chat.ID, translate(lang, "No channels, notifications are only sent here.")+"\n\n"+channelsUsage(lang))
+			return
+		}
+		var lines []string
+		for _, ch := range channels {
+			lines = append(lines, formatChannel(lang, ch))
+		}
+		b.SendMessage(message.Chat.ID, strings.Join(lines, "\n"))
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		if !b.checkPermission(message, b.canChangeSettings) {
+			return
+		}
+		if len(args) < 2 {
+			b.SendMessage(message.Chat.ID, channelsUsage(lang))
+			return
+		}
+		kind, ok := channelKinds[args[1]]
+		if !ok {
+			b.SendMessage(message.Chat.ID, translate(lang, "Unknown channel kind.")+"\n\n"+channelsUsage(lang))
+			return
+		}
+
+		ch := NotificationChannel{ChatID: message.Chat.ID, Kind: args[1]}
+		var kindArgs []string
+		for _, arg := range args[2:] {
+			if !strings.HasPrefix(arg, "target=") {
+				kindArgs = append(kindArgs, arg)
+				continue
+			}
+			id, err := strconv.Atoi(strings.TrimPrefix(arg, "target="))
+			if err != nil {
+				b.SendMessage(message.Chat.ID, translate(lang, "Invalid ID"))
+				return
+			}
+			target, err := b.DB.GetTarget(id)
+			if err != nil || !b.isVisibleTo(target, message.Chat.ID) {
+				b.SendMessage(message.Chat.ID, translate(lang, "No target with such ID found"))
+				return
+			}
+			ch.TargetID = target.ID
+		}
+		if err := kind.Parse(b, message, &ch, kindArgs); err != nil {
+			b.SendMessage(message.Chat.ID, translate(
+				lang,
+				"%v\n\nUsage: /channels add %v %v [target=ID]",
+				replaceHTML(translateError(lang, err)), ch.Kind, replaceHTML(kind.Usage)))
+			return
+		}
+		if err := b.DB.CreateChannel(&ch); err != nil {
+			internalError(err)
+			return
+		}
+		b.SendMessage(message.Chat.ID, translate(lang, "Channel was added: ")+formatChannel(lang, ch))
+	case "delete":
+		if !b.checkPermission(message, b.canChangeSettings) {
+			return
+		}
+		if len(args) != 2 {
+			b.SendMessage(message.Chat.ID, translate(lang, "Usage: /channels delete ID"))
+			return
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			b.SendMessage(message.Chat.ID, translate(lang, "Invalid ID"))
+			return
+		}
+		if err := b.DB.DeleteChannel(message.Chat.ID, uint(id)); err != nil {
+			internalError(err)
+			return
+		}
+		b.channelNotifiers.forget(uint(id))
+		b.SendMessage(message.Chat.ID, translate(lang, "Channel was deleted"))
+	case "log":
+		records, err := b.DB.GetDeliveries(message.Chat.ID, 20)
+		if err != nil {
+			internalError(err)
+			return
+		}
+		if len(records) == 0 {
+			b.SendMessage(message.Chat.ID, translate(lang, "Nothing was delivered yet"))
+			return
+		}
+		settings, err := b.getChatSettings(message.Chat.ID)
+		if err != nil {
+			fmt.Println(err)
+		}
+		var lines []string
+		for _, rec := range records {
+			result := okStatusEmoji
+			if rec.Error != "" {
+				result = errorStatusEmoji + " " + replaceHTML(rec.Error)
+			}
+			lines = append(lines, translate(
+				lang,
+				"%v %v %v, target %v, incident %v, attempt %v: %v",
+				formatTime(lang, rec.CreatedAt.In(settings.Location()), "2006-01-02 15:04:05"),
+				rec.Notifier, replaceHTML(rec.Destination), rec.TargetID,
+				rec.IncidentID, rec.Attempt, result))
+		}
+		b.SendMessage(message.Chat.ID, strings.Join(lines, "\n"))
+	default:
+		b.SendMessage(message.Chat.ID, channelsUsage(lang))
+	}
+}
@@ -0,0 +1,329 @@
+package telegrambot
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+const (
+	chartWidth  = 800
+	chartHeight = 400
+	// Margins of the plot area inside the image.
+	chartMarginLeft   = 60
+	chartMarginRight  = 20
+	chartMarginTop    = 20
+	chartMarginBottom = 40
+)
+
+var (
+	chartBackground = color.RGBA{255, 255, 255, 255}
+	chartGrid       = color.RGBA{225, 225, 225, 255}
+	chartAxis       = color.RGBA{90, 90, 90, 255}
+	chartLine       = color.RGBA{30, 110, 200, 255}
+	// Semi-transparent red, premultiplied.
+	chartDowntime = color.RGBA{96, 0, 0, 96}
+)
+
+// chartGlyphs is a tiny 3x5 bitmap font for axis labels. Each glyph is
+// five rows of three pixels, "#" is a set pixel.
+var chartGlyphs = map[rune][5]string{
+	'0': {"###", "#.#", "#.#", "#.#", "###"},
+	'1': {".#.", "##.", ".#.", ".#.", "###"},
+	'2': {"###", "..#", "###", "#..", "###"},
+	'3': {"###", "..#", "###", "..#", "###"},
+	'4': {"#.#", "#.#", "###", "..#", "..#"},
+	'5': {"###", "#..", "###", "..#", "###"},
+	'6': {"###", "#..", "###", "#.#", "###"},
+	'7': {"###", "..#", ".#.", ".#.", ".#."},
+	'8': {"###", "#.#", "###", "#.#", "###"},
+	'9': {"###", "#.#", "###", "..#", "###"},
+	':': {"...", ".#.", "...", ".#.", "..."},
+	'-': {"...", "...", "###", "...", "..."},
+	'.': {"...", "...", "...", "...", ".#."},
+	' ': {"...", "...", "...", "...", "..."},
+}
+
+const chartGlyphScale = 2
+
+// chartTextWidth returns the width of the text drawn with drawChartText.
+func chartTextWidth(text string) int {
+	return len([]rune(text)) * 4 * chartGlyphScale
+}
+
+func drawChartText(img *image.RGBA, x, y int, text string, c color.Color) {
+	for _, rn := range text {
+		glyph, ok := chartGlyphs[rn]
+		if ok {
+			for row, line := range glyph {
+				for col, px := range line {
+					if px != '#' {
+						continue
+					}
+					rect := image.Rect(
+						x+col*chartGlyphScale, y+row*chartGlyphScale,
+						x+(col+1)*chartGlyphScale, y+(row+1)*chartGlyphScale)
+					draw.Draw(img, rect, &image.Uniform{c}, image.Point{}, draw.Src)
+				}
+			}
+		}
+		x += 4 * chartGlyphScale
+	}
+}
+
+func drawChartLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	dx, dy := x1-x0, y1-y0
+	if dx < 0 {
+		dx = -dx
+	}
+	if dy < 0 {
+		dy = -dy
+	}
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	e := dx - dy
+	for {
+		img.Set(x0, y0, c)
+		img.Set(x0, y0+1, c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 > -dy {
+			e -= dy
+			x0 += sx
+		}
+		if e2 < dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// niceStep returns a round grid step, so that about n steps cover max.
+func niceStep(max float64, n int) float64 {
+	raw := max / float64(n)
+	step := 1.0
+	for step*10 <= raw {
+		step *= 10
+	}
+	for _, mult := range []float64{1, 2, 5, 10} {
+		if step*mult >= raw {
+			return step * mult
+		}
+	}
+	return step * 10
+}
+
+// renderChart draws response times of the poll records as a line and failed
+// polls as red bands over the period from since to until, and encodes the
+// image as PNG.
+func renderChart(records []PollRecord, since, until time.Time, maxGap time.Duration, loc *time.Location, lang string) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{chartBackground}, image.Point{}, draw.Src)
+
+	plot := image.Rect(
+		chartMarginLeft, chartMarginTop,
+		chartWidth-chartMarginRight, chartHeight-chartMarginBottom)
+	span := until.Sub(since)
+	xOf := func(t time.Time) int {
+		return plot.Min.X + int(float64(plot.Dx())*float64(t.Sub(since))/float64(span))
+	}
+
+	// Average response time of OK polls and downtime flag of each pixel column.
+	sums := make([]time.Duration, plot.Dx()+1)
+	counts := make([]int, plot.Dx()+1)
+	down := make([]bool, plot.Dx()+1)
+	var maxLatency time.Duration
+	for i, rec := range records {
+		x := xOf(rec.Time)
+		if x < plot.Min.X || x > plot.Max.X {
+			continue
+		}
+
+		if rec.Type != monitor.StatusOK {
+			end := until
+			if i+1 < len(records) {
+				end = records[i+1].Time
+			}
+			if end.Sub(rec.Time) > maxGap {
+				end = rec.Time.Add(maxGap)
+			}
+			for col := x; col == x || col < xOf(end); col++ {
+				if col <= plot.Max.X {
+					down[col-plot.Min.X] = true
+				}
+			}
+			continue
+		}
+
+		sums[x-plot.Min.X] += rec.ResponseTime
+		counts[x-plot.Min.X]++
+		if rec.ResponseTime > maxLatency {
+			maxLatency = rec.ResponseTime
+		}
+	}
+
+	maxMs := float64(maxLatency) / float64(time.Millisecond)
+	if maxMs < 10 {
+		maxMs = 10
+	}
+	step := niceStep(maxMs, 5)
+	top := step * float64(int(maxMs/step)+1)
+	yOf := func(ms float64) int {
+		return plot.Max.Y - int(float64(plot.Dy())*ms/top)
+	}
+
+	// Horizontal grid with response time labels.
+	for v := 0.0; v <= top; v += step {
+		y := yOf(v)
+		drawChartLine(img, plot.Min.X, y, plot.Max.X, y, chartGrid)
+		label := strconv.Itoa(int(v))
+		drawChartText(img, plot.Min.X-8-chartTextWidth(label), y-5, label, chartAxis)
+	}
+
+	// Vertical grid with time labels.
+	layout := "15:04"
+	if span > 2*24*time.Hour {
+		layout = "01-02"
+	}
+	for i := 0; i <= 6; i++ {
+		t := since.Add(span * time.Duration(i) / 6)
+		x := xOf(t)
+		drawChartLine(img, x, plot.Min.Y, x, plot.Max.Y, chartGrid)
+		label := formatTime(lang, t.In(loc), layout)
+		drawChartText(img, x-chartTextWidth(label)/2, plot.Max.Y+10, label, chartAxis)
+	}
+
+	for i, isDown := range down {
+		if isDown {
+			band := image.Rect(plot.Min.X+i, plot.Min.Y, plot.Min.X+i+1, plot.Max.Y)
+			draw.Draw(img, band, &image.Uniform{chartDowntime}, image.Point{}, draw.Over)
+		}
+	}
+
+	drawChartLine(img, plot.Min.X, plot.Min.Y, plot.Min.X, plot.Max.Y, chartAxis)
+	drawChartLine(img, plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y, chartAxis)
+
+	// The line is interrupted by downtime.
+	prevX, prevY := -1, 0
+	for i := range sums {
+		if down[i] {
+			prevX = -1
+		}
+		if counts[i] == 0 {
+			continue
+		}
+		ms := float64(sums[i]/time.Duration(counts[i])) / float64(time.Millisecond)
+		x, y := plot.Min.X+i, yOf(ms)
+		if prevX >= 0 {
+			drawChartLine(img, prevX, prevY, x, y, chartLine)
+		}
+		prevX, prevY = x, y
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// sendGraph handles /graph command with arguments "ID [period]".
+func (b *Bot) sendGraph(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 || len(args) > 2 {
+		b.SendMessage(message.Chat.ID, translate(lang, "Usage: /graph ID [24h|7d|30d]"))
+		return
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		b.SendMessage(message.Chat.ID, translate(lang, "Invalid ID"))
+		return
+	}
+	target, err := b.DB.GetTarget(id)
+	if err != nil || !b.isVisibleTo(target, message.Chat.ID) {
+		b.SendMessage(message.Chat.ID, translate(lang, "No target with such ID found"))
+		return
+	}
+
+	period := 24 * time.Hour
+	if len(args) == 2 {
+		period, err = parsePeriod(args[1])
+		if err != nil {
+			b.SendMessage(message.Chat.ID, replaceHTML(translateError(lang, err)))
+			return
+		}
+	}
+	if period > b.HistoryRetention {
+		b.SendMessage(message.Chat.ID, translate(
+			lang,
+			"History is only kept for %v", formatPeriod(lang, b.HistoryRetention)))
+		return
+	}
+
+	internalError := func(err error) {
+		fmt.Println(err)
+		b.SendMessage(
+			message.Chat.ID,
+			translate(
+				lang,
+				"Error while building the graph, please contact the administrator: %v",
+				b.AdminNickname))
+	}
+
+	settings, err := b.getChatSettings(message.Chat.ID)
+	if err != nil {
+		internalError(err)
+		return
+	}
+
+	until := time.Now()
+	since := until.Add(-period)
+	records, err := b.DB.GetPollHistory(target.ID, since)
+	if err != nil {
+		internalError(err)
+		return
+	}
+	if len(records) == 0 {
+		b.SendMessage(message.Chat.ID, translate(lang, "No data for this period yet"))
+		return
+	}
+
+	maxGap := 2 * b.Monitor.Scheduler.Interval
+	chart, err := renderChart(records, since, until, maxGap, settings.Location(), lang)
+	if err != nil {
+		internalError(err)
+		return
+	}
+
+	stats := computeStats(records, until, maxGap)
+	photo := tgbotapi.NewPhotoUpload(message.Chat.ID, tgbotapi.FileBytes{
+		Name:  "graph.png",
+		Bytes: chart,
+	})
+	photo.Caption = translate(
+		lang,
+		"%v, last %v: response time, ms; downtime in red. %.2f%% uptime, %v ms avg",
+		target.Title, formatPeriod(lang, period), stats.Uptime(),
+		int64(stats.AvgLatency/time.Millisecond))
+	if _, err := b.TgBot.Send(photo); err != nil {
+		fmt.Println(err)
+	}
+}
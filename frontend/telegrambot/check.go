@@ -0,0 +1,194 @@
+package telegrambot
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// checkLimiter allows at most one /check per interval in each chat.
+type checkLimiter struct {
+	mu        sync.Mutex
+	lastCheck map[int64]time.Time
+}
+
+// Allow reports whether the chat may run a check now and, if so, remembers
+// the time of the check. Otherwise it returns how long to wait.
+func (cl *checkLimiter) Allow(chatID int64, interval time.Duration) (bool, time.Duration) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.lastCheck == nil {
+		cl.lastCheck = map[int64]time.Time{}
+	}
+	// Forget chats, which may check again anyway.
+	for id, last := range cl.lastCheck {
+		if time.Since(last) >= interval {
+			delete(cl.lastCheck, id)
+		}
+	}
+	if last, ok := cl.lastCheck[chatID]; ok {
+		if wait := interval - time.Since(last); wait > 0 {
+			return false, wait
+		}
+	}
+	cl.lastCheck[chatID] = time.Now()
+	return true, 0
+}
+
+// hostBlocklist matches hosts against a list of patterns. A pattern is either
+// a host name ("localhost"), a domain wildcard ("*.internal") or a network in
+// CIDR notation ("10.0.0.0/8"). Host names are resolved and their addresses
+// are matched against the networks as well.
+type hostBlocklist struct {
+	hosts    []string
+	suffixes []string
+	nets     []*net.IPNet
+}
+
+func newHostBlocklist(patterns []string) (*hostBlocklist, error) {
+	bl := &hostBlocklist{}
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case strings.Contains(pattern, "/"):
+			_, ipnet, err := net.ParseCIDR(pattern)
+			if err != nil {
+				return nil, err
+			}
+			bl.nets = append(bl.nets, ipnet)
+		case strings.HasPrefix(pattern, "*."):
+			bl.suffixes = append(bl.suffixes, pattern[1:])
+		default:
+			bl.hosts = append(bl.hosts, pattern)
+		}
+	}
+	return bl, nil
+}
+
+// IsBlocked reports whether the host matches any of the patterns. Hosts,
+// which can't be resolved, are blocked, since their addresses are unknown.
+func (bl *hostBlocklist) IsBlocked(host string) (bool, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, h := range bl.hosts {
+		if host == h {
+			return true, nil
+		}
+	}
+	for _, suffix := range bl.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return true, nil
+		}
+	}
+	if len(bl.nets) == 0 {
+		return false, nil
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return true, err
+	}
+	for _, ip := range ips {
+		for _, ipnet := range bl.nets {
+			if ipnet.Contains(ip) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// SetCheckBlocklist sets the patterns of hosts, which cannot be polled with
+// /check. See hostBlocklist for the pattern format.
+func (b *Bot) SetCheckBlocklist(patterns []string) error {
+	bl, err := newHostBlocklist(patterns)
+	if err != nil {
+		return err
+	}
+	b.checkBlocklist = bl
+	return nil
+}
+
+// checkURL polls the URL for /check. Besides the pre-check of the URL's host,
+// the blocklist is enforced on every redirect, and its networks on every
+// connection, so that redirects and DNS changes can't reach blocked hosts
+// either.
+func (b *Bot) checkURL(chatID int64, url string) monitor.Status {
+	poller := b.Monitor.Scheduler.Poller
+	var filter *monitor.IPFilter
+	if !b.DB.isTrustedChat(chatID) {
+		filter = poller.IPFilter
+	}
+	if bl := b.checkBlocklist; bl != nil {
+		filter = &monitor.IPFilter{
+			Deny: bl.nets,
+			And:  filter,
+			CheckHost: func(host string) error {
+				blocked, err := bl.IsBlocked(host)
+				if err != nil {
+					fmt.Println(err)
+				}
+				if blocked {
+					return fmt.Errorf("redirects to %v are not allowed", host)
+				}
+				return nil
+			},
+		}
+	}
+	return poller.PollWithFilter(url, filter)
+}
+
+// pollURL polls the URL once on behalf of the chat.
+func (b *Bot) pollURL(chatID int64, url string) monitor.Status {
+	return b.Monitor.Scheduler.Poller.PollTarget(monitor.Target{
+		URL:             url,
+		AllowAnyAddress: b.DB.isTrustedChat(chatID),
+	})
+}
+
+func (b *Bot) runCheck(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	rawURL := strings.TrimSpace(message.CommandArguments())
+	if rawURL == "" {
+		b.SendMessage(message.Chat.ID, translate(lang, "Usage: /check URL"))
+		return
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err == nil && parsed.Host == "" {
+		parsed, err = url.Parse("http://" + rawURL)
+	}
+	if err != nil || parsed.Hostname() == "" {
+		b.SendMessage(message.Chat.ID, translate(lang, "Error while parsing url"))
+		return
+	}
+
+	if b.checkBlocklist != nil {
+		blocked, err := b.checkBlocklist.IsBlocked(parsed.Hostname())
+		if err != nil {
+			fmt.Println(err)
+		}
+		if blocked {
+			b.SendMessage(message.Chat.ID, translate(lang, "Sorry, this host cannot be checked"))
+			return
+		}
+	}
+
+	if ok, wait := b.checkLimiter.Allow(message.Chat.ID, b.CheckInterval); !ok {
+		b.SendMessage(message.Chat.ID, translate(
+			lang,
+			"Too many checks, please try again in %v seconds", int64(wait/time.Second)+1))
+		return
+	}
+
+	status := b.checkURL(message.Chat.ID, rawURL)
+	b.SendMessage(message.Chat.ID, fmt.Sprintf(
+		"%v <b>%v</b>\n<pre>%v</pre>",
+		statusEmoji(status.Type), replaceHTML(rawURL), replaceHTML(status.ExpandedString())))
+}
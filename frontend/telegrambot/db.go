@@ -1,7 +1,10 @@
 package telegrambot
 
 import (
+	"time"
+
 	"github.com/jinzhu/gorm"
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
 	"github.com/yamnikov-oleg/avamon-bot/monitor"
 )
 
@@ -10,13 +13,28 @@ type Record struct {
 	ChatID int64
 	Title  string
 	URL    string
+	// Paused targets are not polled.
+	Paused bool
+	// Notifications of critical targets ignore quiet hours.
+	Critical bool
+	// Space-separated list of lower-case tags, see parseTags.
+	Tags string
+	// Template of notifications about the target, see templates.go. Empty
+	// means the chat's template.
+	Template string `gorm:"type:text"`
+	// Token of the target's share link, empty if the target is not shared.
+	ShareToken string `gorm:"index"`
+	// Deleted targets are kept for a while to be restored with /restore.
+	DeletedAt *time.Time
 }
 
 func (r *Record) ToTarget() monitor.Target {
 	return monitor.Target{
-		ID:    r.ID,
-		Title: r.Title,
-		URL:   r.URL,
+		ID:       r.ID,
+		Title:    r.Title,
+		URL:      r.URL,
+		Tags:     r.TagList(),
+		Critical: r.Critical,
 	}
 }
 
@@ -26,6 +44,17 @@ func (r *Record) ToTarget() monitor.Target {
 
 type TargetsDB struct {
 	DB *gorm.DB
+	// Targets of these chats are polled without the poller's IP filter.
+	TrustedChats []int64
+}
+
+func (t *TargetsDB) isTrustedChat(chatID int64) bool {
+	for _, id := range t.TrustedChats {
+		if id == chatID {
+			return true
+		}
+	}
+	return false
 }
 
 func (t *TargetsDB) DeleteTarget(id int) error {
@@ -47,13 +76,15 @@ func (t *TargetsDB) GetTarget(id int) (*Record, error) {
 
 func (t *TargetsDB) GetTargets() ([]monitor.Target, error) {
 	records := []Record{}
-	err := t.DB.Find(&records).Error
+	err := t.DB.Where("paused = ?", false).Find(&records).Error
 	if err != nil {
 		return nil, err
 	}
 	var targets []monitor.Target
 	for _, record := range records {
-		targets = append(targets, record.ToTarget())
+		target := record.ToTarget()
+		target.AllowAnyAddress = t.isTrustedChat(record.ChatID)
+		targets = append(targets, target)
 	}
 	return targets, nil
 }
@@ -67,14 +98,637 @@ func (t *TargetsDB) GetCurrentTargets(chatID int64) ([]Record, error) {
 	return records, nil
 }
 
-func (t *TargetsDB) CreateTarget(record Record) error {
-	err := t.DB.Create(&record).Error
+func (t *TargetsDB) UpdateTarget(record Record) error {
+	return t.DB.Save(&record).Error
+}
+
+// CreateTarget inserts the record and sets its ID.
+func (t *TargetsDB) CreateTarget(record *Record) error {
+	err := t.DB.Create(record).Error
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// GetDeletedTargets returns the chat's targets deleted after the given time.
+func (t *TargetsDB) GetDeletedTargets(chatID int64, since time.Time) ([]Record, error) {
+	records := []Record{}
+	err := t.DB.Unscoped().
+		Where("chat_id = ? AND deleted_at IS NOT NULL AND deleted_at > ?", chatID, since).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (t *TargetsDB) RestoreTarget(id int) error {
+	return t.DB.Unscoped().Model(&Record{}).Where("id = ?", id).Update("deleted_at", nil).Error
+}
+
+// PurgeDeletedTargets permanently removes targets deleted before the given
+// time and subscriptions to them.
+func (t *TargetsDB) PurgeDeletedTargets(before time.Time) error {
+	err := t.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", before).Delete(Record{}).Error
+	if err != nil {
+		return err
+	}
+	return t.DB.Where("target_id NOT IN (SELECT id FROM records)").Delete(Subscription{}).Error
+}
+
+// Subscription makes a chat receive notifications about a target owned by
+// another chat.
+type Subscription struct {
+	ID       uint  `gorm:"primary_key"`
+	TargetID uint  `gorm:"index"`
+	ChatID   int64 `gorm:"index"`
+}
+
+// Subscribe subscribes the chat to the target. It does nothing if the chat
+// is subscribed already.
+func (t *TargetsDB) Subscribe(targetID uint, chatID int64) error {
+	return t.DB.Where(Subscription{TargetID: targetID, ChatID: chatID}).
+		FirstOrCreate(&Subscription{}).Error
+}
+
+func (t *TargetsDB) Unsubscribe(targetID uint, chatID int64) error {
+	return t.DB.Where("target_id = ? AND chat_id = ?", targetID, chatID).
+		Delete(Subscription{}).Error
+}
+
+// GetSubscribers returns IDs of the chats subscribed to the target, not
+// including the chat owning it.
+func (t *TargetsDB) GetSubscribers(targetID uint) ([]int64, error) {
+	var subs []Subscription
+	err := t.DB.Where("target_id = ?", targetID).Find(&subs).Error
+	if err != nil {
+		return nil, err
+	}
+	var chats []int64
+	for _, sub := range subs {
+		chats = append(chats, sub.ChatID)
+	}
+	return chats, nil
+}
+
+// GetSubscribedTargets returns targets of other chats the chat is subscribed
+// to.
+func (t *TargetsDB) GetSubscribedTargets(chatID int64) ([]Record, error) {
+	records := []Record{}
+	err := t.DB.
+		Where("id IN (SELECT target_id FROM subscriptions WHERE chat_id = ?)", chatID).
+		Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// GetVisibleTargets returns targets owned by the chat followed by targets it
+// is subscribed to.
+func (t *TargetsDB) GetVisibleTargets(chatID int64) ([]Record, error) {
+	owned, err := t.GetCurrentTargets(chatID)
+	if err != nil {
+		return nil, err
+	}
+	subscribed, err := t.GetSubscribedTargets(chatID)
+	if err != nil {
+		return nil, err
+	}
+	return append(owned, subscribed...), nil
+}
+
+// IsSubscribed reports whether the chat is subscribed to the target.
+func (t *TargetsDB) IsSubscribed(targetID uint, chatID int64) (bool, error) {
+	var count int
+	err := t.DB.Model(&Subscription{}).
+		Where("target_id = ? AND chat_id = ?", targetID, chatID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetTargetByShareToken returns the target shared with the token.
+func (t *TargetsDB) GetTargetByShareToken(token string) (*Record, error) {
+	r := Record{}
+	err := t.DB.Where("share_token = ?", token).First(&r).Error
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// AuditEntry records a change of a target made by a Telegram user.
+type AuditEntry struct {
+	ID       uint `gorm:"primary_key"`
+	ChatID   int64
+	UserID   int
+	Username string
+	// One of Action* constants.
+	Action   string
+	TargetID uint
+	// JSON-encoded Record before and after the change. Empty if there was no
+	// record before (created) or after (deleted) the change.
+	Before    string
+	After     string
+	CreatedAt time.Time
+}
+
+func (t *TargetsDB) AddAuditEntry(entry AuditEntry) error {
+	return t.DB.Create(&entry).Error
+}
+
+// GetAuditEntries returns at most limit latest audit entries of the chat,
+// newest first.
+func (t *TargetsDB) GetAuditEntries(chatID int64, limit int) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	err := t.DB.Where("chat_id = ?", chatID).Order("id desc").Limit(limit).Find(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SessionRecord is a persisted dialog of a user, which is still in progress.
+type SessionRecord struct {
+	ID         uint `gorm:"primary_key"`
+	ChatID     int64
+	UserID     int
+	Kind       string
+	Stage      int
+	Data       string
+	LastActive time.Time
+}
+
+func (t *TargetsDB) SaveSession(record SessionRecord) error {
+	err := t.DeleteSession(record.ChatID, record.UserID)
+	if err != nil {
+		return err
+	}
+	return t.DB.Create(&record).Error
+}
+
+func (t *TargetsDB) DeleteSession(chatID int64, userID int) error {
+	return t.DB.Where("chat_id = ? AND user_id = ?", chatID, userID).Delete(SessionRecord{}).Error
+}
+
+func (t *TargetsDB) GetSessions() ([]SessionRecord, error) {
+	records := []SessionRecord{}
+	err := t.DB.Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ChatSettings holds preferences of a chat, which chat administrators can
+// change with /settings.
+type ChatSettings struct {
+	ID     uint  `gorm:"primary_key"`
+	ChatID int64 `gorm:"unique_index"`
+	// Who can manage the chat's targets, one of Policy* constants.
+	ManagePolicy string
+	// Notify about the first status of a target even if it's OK.
+	NotifyFirstOK bool
+	// Do not notify when a target goes up again.
+	MuteRecovery bool
+	// Send notifications about recovery without sound.
+	SilentRecovery bool
+	// Omit response time, error message and HTTP status from notifications.
+	HideDetails bool
+	// Name of the IANA time zone for displayed times. Empty means UTC.
+	Timezone string
+	// One of Verbosity* constants. Empty means VerbosityNormal.
+	Verbosity string
+	// Daily interval of format HH:MM-HH:MM in the chat's time zone. Empty
+	// means no quiet hours.
+	QuietHours string
+	// One of QuietMode* constants. Empty means QuietModeSilent.
+	QuietMode string
+	// When to send uptime reports, e.g. "daily 09:00" or "weekly mon 09:00".
+	// Empty means no scheduled reports.
+	ReportSchedule string
+	// When the last scheduled report was sent.
+	LastReportAt time.Time
+	// Template of the chat's notifications, see templates.go. Empty means
+	// the bot's template.
+	Template string `gorm:"type:text"`
+	// One of Language* constants. Empty means the language detected by
+	// the members' Telegram, see ChatLanguage.
+	Language string
+}
+
+// Location returns the time zone of the chat.
+func (s *ChatSettings) Location() *time.Location {
+	if s.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// AllowedUser is a user allowed to manage targets of a chat with
+// PolicyAllowlist policy.
+type AllowedUser struct {
+	ID       uint `gorm:"primary_key"`
+	ChatID   int64
+	UserID   int
+	Username string
+}
+
+// GetChatSettings returns saved settings of the chat. If the chat has
+// no saved settings, it returns ok=false, err=nil.
+func (t *TargetsDB) GetChatSettings(chatID int64) (ChatSettings, bool, error) {
+	settings := ChatSettings{}
+	err := t.DB.Where("chat_id = ?", chatID).First(&settings).Error
+	if err == gorm.ErrRecordNotFound {
+		return ChatSettings{}, false, nil
+	}
+	if err != nil {
+		return ChatSettings{}, false, err
+	}
+	return settings, true, nil
+}
+
+func (t *TargetsDB) SaveChatSettings(settings ChatSettings) error {
+	if settings.ID == 0 {
+		return t.DB.Create(&settings).Error
+	}
+	return t.DB.Save(&settings).Error
+}
+
+// SetLastReportAt records when the chat's scheduled report was sent
+// without touching its other settings.
+func (t *TargetsDB) SetLastReportAt(chatID int64, at time.Time) error {
+	return t.DB.Model(&ChatSettings{}).Where("chat_id = ?", chatID).UpdateColumn("last_report_at", at).Error
+}
+
+// ChatLanguage is the language of a chat detected by the language of its
+// members' Telegram.
+type ChatLanguage struct {
+	ID       uint  `gorm:"primary_key"`
+	ChatID   int64 `gorm:"unique_index"`
+	Language string
+}
+
+// GetChatLanguage returns the detected language of the chat, empty if it's
+// unknown.
+func (t *TargetsDB) GetChatLanguage(chatID int64) (string, error) {
+	cl := ChatLanguage{}
+	err := t.DB.Where("chat_id = ?", chatID).First(&cl).Error
+	if err == gorm.ErrRecordNotFound {
+		return "", nil
+	}
+	return cl.Language, err
+}
+
+func (t *TargetsDB) SetChatLanguage(chatID int64, lang string) error {
+	return t.DB.Where(ChatLanguage{ChatID: chatID}).
+		Assign(ChatLanguage{Language: lang}).
+		FirstOrCreate(&ChatLanguage{}).Error
+}
+
+func (t *TargetsDB) GetAllowedUsers(chatID int64) ([]AllowedUser, error) {
+	users := []AllowedUser{}
+	err := t.DB.Where("chat_id = ?", chatID).Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (t *TargetsDB) IsUserAllowed(chatID int64, userID int) (bool, error) {
+	var count int
+	err := t.DB.Model(&AllowedUser{}).Where("chat_id = ? AND user_id = ?", chatID, userID).Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (t *TargetsDB) AllowUser(user AllowedUser) error {
+	allowed, err := t.IsUserAllowed(user.ChatID, user.UserID)
+	if err != nil || allowed {
+		return err
+	}
+	return t.DB.Create(&user).Error
+}
+
+func (t *TargetsDB) DisallowUser(chatID int64, userID int) error {
+	return t.DB.Where("chat_id = ? AND user_id = ?", chatID, userID).Delete(AllowedUser{}).Error
+}
+
+// QueuedNotification is a status change which happened during quiet hours
+// and will be delivered in a digest.
+type QueuedNotification struct {
+	ID         uint `gorm:"primary_key"`
+	ChatID     int64
+	TargetID   uint
+	Title      string
+	URL        string
+	StatusType string
+	Err        string
+	CreatedAt  time.Time
+}
+
+func (t *TargetsDB) QueueNotification(qn QueuedNotification) error {
+	return t.DB.Create(&qn).Error
+}
+
+// GetQueuedChats returns IDs of chats which have queued notifications.
+func (t *TargetsDB) GetQueuedChats() ([]int64, error) {
+	var chatIDs []int64
+	err := t.DB.Model(&QueuedNotification{}).Pluck("DISTINCT chat_id", &chatIDs).Error
+	if err != nil {
+		return nil, err
+	}
+	return chatIDs, nil
+}
+
+// GetQueuedNotifications returns the chat's queued notifications, oldest first.
+func (t *TargetsDB) GetQueuedNotifications(chatID int64) ([]QueuedNotification, error) {
+	queued := []QueuedNotification{}
+	err := t.DB.Where("chat_id = ?", chatID).Order("id").Find(&queued).Error
+	if err != nil {
+		return nil, err
+	}
+	return queued, nil
+}
+
+// DeleteQueuedNotifications removes the chat's queued notifications with IDs
+// up to and including lastID.
+func (t *TargetsDB) DeleteQueuedNotifications(chatID int64, lastID uint) error {
+	return t.DB.Where("chat_id = ? AND id <= ?", chatID, lastID).Delete(QueuedNotification{}).Error
+}
+
+// PollRecord is a single result of polling a target.
+type PollRecord struct {
+	ID             uint      `gorm:"primary_key"`
+	TargetID       uint      `gorm:"index:idx_poll_target_time"`
+	Time           time.Time `gorm:"index:idx_poll_target_time"`
+	Type           monitor.StatusType
+	ResponseTime   time.Duration
+	HTTPStatusCode int
+}
+
+// AddStatus implements monitor.HistoryStore for TargetsDB.
+func (t *TargetsDB) AddStatus(target monitor.Target, status monitor.Status, at time.Time) error {
+	return t.DB.Create(&PollRecord{
+		TargetID:       target.ID,
+		Time:           at,
+		Type:           status.Type,
+		ResponseTime:   status.ResponseTime,
+		HTTPStatusCode: status.HTTPStatusCode,
+	}).Error
+}
+
+// AddStatuses implements monitor.BatchHistoryStore for TargetsDB, saving
+// the records in a single transaction.
+func (t *TargetsDB) AddStatuses(records []monitor.HistoryRecord) error {
+	tx := t.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	txdb := &TargetsDB{DB: tx, TrustedChats: t.TrustedChats}
+
+	for _, rec := range records {
+		if err := txdb.AddStatus(rec.Target, rec.Status, rec.Time); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// GetPollHistory returns the target's poll records made since the given time,
+// oldest first.
+func (t *TargetsDB) GetPollHistory(targetID uint, since time.Time) ([]PollRecord, error) {
+	records := []PollRecord{}
+	err := t.DB.Where("target_id = ? AND time >= ?", targetID, since).Order("time").Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// PurgePollHistory removes poll records made before the given time.
+func (t *TargetsDB) PurgePollHistory(before time.Time) error {
+	return t.DB.Where("time < ?", before).Delete(PollRecord{}).Error
+}
+
+// Incident is a period of a target's failure, from the first failed status
+// until the target is OK again.
+type Incident struct {
+	ID         uint `gorm:"primary_key"`
+	TargetID   uint `gorm:"index"`
+	StartedAt  time.Time
+	ResolvedAt *time.Time
+	// Set when someone acknowledges the incident in an incident management
+	// system.
+	AcknowledgedAt *time.Time
+	AcknowledgedBy string
+}
+
+// GetOpenIncident returns the target's unresolved incident or nil if there
+// is none.
+func (t *TargetsDB) GetOpenIncident(targetID uint) (*Incident, error) {
+	inc := Incident{}
+	err := t.DB.Where("target_id = ? AND resolved_at IS NULL", targetID).First(&inc).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inc, nil
+}
+
+func (t *TargetsDB) OpenIncident(targetID uint, at time.Time) (*Incident, error) {
+	inc := Incident{TargetID: targetID, StartedAt: at}
+	err := t.DB.Create(&inc).Error
+	if err != nil {
+		return nil, err
+	}
+	return &inc, nil
+}
+
+func (t *TargetsDB) ResolveIncident(id uint, at time.Time) error {
+	return t.DB.Model(&Incident{}).Where("id = ?", id).Update("resolved_at", at).Error
+}
+
+// GetIncident returns the incident of the target with the ID or nil if there
+// is none.
+func (t *TargetsDB) GetIncident(targetID, id uint) (*Incident, error) {
+	inc := Incident{}
+	err := t.DB.Where("target_id = ? AND id = ?", targetID, id).First(&inc).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inc, nil
+}
+
+// AcknowledgeIncident marks the incident as acknowledged, or clears the mark
+// if at is nil.
+func (t *TargetsDB) AcknowledgeIncident(id uint, by string, at *time.Time) error {
+	return t.DB.Model(&Incident{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"acknowledged_at": at,
+		"acknowledged_by": by,
+	}).Error
+}
+
+// NotificationChannel is an additional destination of a chat's
+// notifications, e.g. a webhook.
+type NotificationChannel struct {
+	ID     uint  `gorm:"primary_key"`
+	ChatID int64 `gorm:"index"`
+	// If not zero, only events of this target are delivered, otherwise events
+	// of all the chat's targets.
+	TargetID uint
+	// Key in channelKinds.
+	Kind string
+	// Kind-specific address, e.g. URL of a webhook.
+	Destination string
+	// Kind-specific secret, e.g. the key to sign webhook requests with.
+	Secret    string
+	CreatedAt time.Time
+}
+
+func (t *TargetsDB) CreateChannel(ch *NotificationChannel) error {
+	return t.DB.Create(ch).Error
+}
+
+func (t *TargetsDB) DeleteChannel(chatID int64, id uint) error {
+	return t.DB.Where("chat_id = ? AND id = ?", chatID, id).Delete(NotificationChannel{}).Error
+}
+
+func (t *TargetsDB) GetChatChannels(chatID int64) ([]NotificationChannel, error) {
+	channels := []NotificationChannel{}
+	err := t.DB.Where("chat_id = ?", chatID).Order("id").Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// GetTargetChannels returns channels of the chats, which receive events of
+// the target.
+func (t *TargetsDB) GetTargetChannels(chatIDs []int64, targetID uint) ([]NotificationChannel, error) {
+	channels := []NotificationChannel{}
+	err := t.DB.Where("chat_id IN (?) AND (target_id = 0 OR target_id = ?)", chatIDs, targetID).
+		Find(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// DeliveryRecord is a logged attempt to deliver an event to a notification
+// channel.
+type DeliveryRecord struct {
+	ID uint `gorm:"primary_key"`
+	// Zero for globally configured notifiers.
+	ChatID      int64 `gorm:"index"`
+	Notifier    string
+	Destination string
+	IncidentID  string
+	TargetID    uint
+	Attempt     int
+	// Empty if the attempt succeeded.
+	Error     string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+func (t *TargetsDB) addDelivery(chatID int64, d notify.Delivery) error {
+	rec := DeliveryRecord{
+		ChatID:      chatID,
+		Notifier:    d.Notifier,
+		Destination: d.Destination,
+		IncidentID:  d.IncidentID,
+		TargetID:    d.TargetID,
+		Attempt:     d.Attempt,
+		CreatedAt:   d.Time,
+	}
+	if d.Err != nil {
+		rec.Error = d.Err.Error()
+	}
+	return t.DB.Create(&rec).Error
+}
+
+// LogDelivery implements notify.DeliveryLog for globally configured
+// notifiers.
+func (t *TargetsDB) LogDelivery(d notify.Delivery) error {
+	return t.addDelivery(0, d)
+}
+
+// GetDeliveries returns the chat's latest delivery attempts, newest first.
+func (t *TargetsDB) GetDeliveries(chatID int64, limit int) ([]DeliveryRecord, error) {
+	records := []DeliveryRecord{}
+	err := t.DB.Where("chat_id = ?", chatID).Order("id desc").Limit(limit).Find(&records).Error
+	if err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// PurgeDeliveries removes delivery records made before the given time.
+func (t *TargetsDB) PurgeDeliveries(before time.Time) error {
+	return t.DB.Where("created_at < ?", before).Delete(DeliveryRecord{}).Error
+}
+
+// HookRun is a run of an exec hook on a target's status change.
+type HookRun struct {
+	ID uint `gorm:"primary_key"`
+	// Zero if the status change wasn't a part of an incident.
+	IncidentID uint `gorm:"index"`
+	TargetID   uint `gorm:"index"`
+	Command    string
+	ExitCode   int
+	Error      string
+	Output     string `gorm:"type:text"`
+	Duration   time.Duration
+	CreatedAt  time.Time
+}
+
+func (t *TargetsDB) CreateHookRun(run *HookRun) error {
+	return t.DB.Create(run).Error
+}
+
+// GetHookRuns returns the latest runs of hooks on the target's status
+// changes, newest first.
+func (t *TargetsDB) GetHookRuns(targetID uint, limit int) ([]HookRun, error) {
+	runs := []HookRun{}
+	err := t.DB.Where("target_id = ?", targetID).Order("id DESC").Limit(limit).Find(&runs).Error
+	if err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// PurgeHookRuns removes runs of hooks made before the given time.
+func (t *TargetsDB) PurgeHookRuns(before time.Time) error {
+	return t.DB.Where("created_at < ?", before).Delete(HookRun{}).Error
+}
+
+// GetScheduledReportSettings returns settings of chats which have scheduled
+// reports.
+func (t *TargetsDB) GetScheduledReportSettings() ([]ChatSettings, error) {
+	settings := []ChatSettings{}
+	err := t.DB.Where("report_schedule <> ''").Find(&settings).Error
+	if err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
 func (t *TargetsDB) Migrate() {
-	t.DB.AutoMigrate(&Record{})
+	t.DB.AutoMigrate(
+		&Record{}, &SessionRecord{}, &ChatSettings{}, &AllowedUser{}, &AuditEntry{},
+		&QueuedNotification{}, &PollRecord{}, &Subscription{}, &Incident{},
+		&NotificationChannel{}, &DeliveryRecord{}, &HookRun{}, &ChatLanguage{})
 }
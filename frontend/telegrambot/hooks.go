@@ -0,0 +1,128 @@
+package telegrambot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+)
+
+// Output of hooks is cut to this many last characters in follow-up messages
+// and in /hooks, which shows several runs in one message.
+const (
+	maxHookOutputInFollowUp = 1000
+	maxHookOutputInList     = 500
+)
+
+// outputTail returns the last max characters of the output.
+func outputTail(output string, max int) string {
+	runes := []rune(strings.TrimSpace(output))
+	if len(runes) <= max {
+		return string(runes)
+	}
+	return "…" + string(runes[len(runes)-max:])
+}
+
+// formatHookRun describes the run in a message, optionally starting with its
+// time.
+func formatHookRun(lang string, run HookRun, target *Record, loc *time.Location, maxOutput int) string {
+	var line string
+	if loc != nil {
+		line = formatTime(lang, run.CreatedAt.In(loc), "2006-01-02 15:04:05") + " "
+	}
+	line += translate(lang, "Hook <code>%v</code> for <b>%v</b>",
+		replaceHTML(run.Command), replaceHTML(target.Title))
+	if run.Error == "" {
+		line = translate(lang, "%v %v succeeded in %v",
+			okStatusEmoji, line, localizeDuration(lang, run.Duration.Round(time.Millisecond)))
+	} else {
+		line = translate(lang, "%v %v failed in %v: %v",
+			errorStatusEmoji, line, localizeDuration(lang, run.Duration.Round(time.Millisecond)), replaceHTML(run.Error))
+	}
+	if run.IncidentID != 0 {
+		line += translate(lang, " (incident %v)", run.IncidentID)
+	}
+	if output := outputTail(run.Output, maxOutput); output != "" {
+		line += "\n<pre>" + replaceHTML(output) + "</pre>"
+	}
+	return line
+}
+
+// RecordHookRun attaches the result of an exec hook to the target's incident
+// and, if followUp is set, tells the target's chats about it.
+func (b *Bot) RecordHookRun(res notify.ExecResult, followUp bool) {
+	run := HookRun{
+		TargetID: res.Event.Target.ID,
+		Command:  res.Command,
+		ExitCode: res.ExitCode,
+		Output:   res.Output,
+		Duration: res.Duration,
+	}
+	if res.Err != nil {
+		run.Error = res.Err.Error()
+	}
+	if id, err := strconv.ParseUint(res.Event.IncidentID, 10, 0); err == nil {
+		run.IncidentID = uint(id)
+	}
+	if err := b.DB.CreateHookRun(&run); err != nil {
+		fmt.Println(err)
+	}
+
+	if !followUp {
+		return
+	}
+	target, err := b.DB.GetTarget(int(run.TargetID))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, chatID := range b.targetChats(target) {
+		lang := b.chatLanguage(chatID)
+		b.SendMessage(chatID, formatHookRun(lang, run, target, nil, maxHookOutputInFollowUp))
+	}
+}
+
+// sendHookRuns handles /hooks command, which shows the latest runs of hooks
+// on the target's status changes.
+func (b *Bot) sendHookRuns(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	id, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		b.SendMessage(message.Chat.ID, translate(lang, "Usage: /hooks ID"))
+		return
+	}
+	target, err := b.DB.GetTarget(id)
+	if err != nil || !b.isVisibleTo(target, message.Chat.ID) {
+		b.SendMessage(message.Chat.ID, translate(lang, "No target with such ID found"))
+		return
+	}
+
+	runs, err := b.DB.GetHookRuns(target.ID, 5)
+	if err != nil {
+		fmt.Println(err)
+		b.SendMessage(
+			message.Chat.ID,
+			translate(
+				lang,
+				"Error while retrieving the hooks, please contact the administrator: %v",
+				b.AdminNickname))
+		return
+	}
+	if len(runs) == 0 {
+		b.SendMessage(message.Chat.ID, translate(lang, "No hooks were run for this target yet"))
+		return
+	}
+
+	settings, err := b.getChatSettings(message.Chat.ID)
+	if err != nil {
+		fmt.Println(err)
+	}
+	var lines []string
+	for _, run := range runs {
+		lines = append(lines, formatHookRun(lang, run, target, settings.Location(), maxHookOutputInList))
+	}
+	b.SendMessage(message.Chat.ID, strings.Join(lines, "\n\n"))
+}
@@ -0,0 +1,504 @@
+package telegrambot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"gopkg.in/yaml.v2"
+)
+
+// Maximum size of an imported file.
+const maxImportSize = 1 << 20
+
+// targetDocument is a target as it appears in exported and imported files.
+type targetDocument struct {
+	// ID of an existing target. Imported rows without ID are matched to
+	// existing targets by URL.
+	ID       uint     `json:"id,omitempty" yaml:"id,omitempty"`
+	Title    string   `json:"title" yaml:"title"`
+	URL      string   `json:"url" yaml:"url"`
+	Paused   bool     `json:"paused,omitempty" yaml:"paused,omitempty"`
+	Critical bool     `json:"critical,omitempty" yaml:"critical,omitempty"`
+	Tags     []string `json:"tags,omitempty" yaml:"tags,omitempty"`
+	// Template of the target's notifications, see templates.go.
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
+}
+
+var csvHeader = []string{"id", "title", "url", "paused", "critical", "tags", "template"}
+
+func newTargetDocument(rec Record) targetDocument {
+	return targetDocument{
+		ID:       rec.ID,
+		Title:    rec.Title,
+		URL:      rec.URL,
+		Paused:   rec.Paused,
+		Critical: rec.Critical,
+		Tags:     rec.TagList(),
+		Template: rec.Template,
+	}
+}
+
+// apply writes the document's fields into the record.
+func (td targetDocument) apply(rec *Record) {
+	rec.Title = td.Title
+	rec.URL = td.URL
+	rec.Paused = td.Paused
+	rec.Critical = td.Critical
+	rec.setTags(append([]string(nil), td.Tags...))
+	rec.Template = td.Template
+}
+
+func encodeTargetDocuments(format string, docs []targetDocument) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(docs, "", "  ")
+	case "yaml":
+		return yaml.Marshal(docs)
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		w.Write(csvHeader)
+		for _, doc := range docs {
+			w.Write([]string{
+				strconv.FormatUint(uint64(doc.ID), 10),
+				doc.Title,
+				doc.URL,
+				strconv.FormatBool(doc.Paused),
+				strconv.FormatBool(doc.Critical),
+				strings.Join(doc.Tags, " "),
+				doc.Template,
+			})
+		}
+		w.Flush()
+		return buf.Bytes(), w.Error()
+	}
+	return nil, errorf("Unknown format %q, use json, csv or yaml", format)
+}
+
+// decodeTargetDocuments parses the file, choosing the format by the file's
+// extension or, if it's unknown, by the content. Besides the native formats,
+// monitor lists of other uptime tools are accepted (see thirdparty.go).
+// Entries of the file, which couldn't be translated into targets, are
+// described in skipped.
+func decodeTargetDocuments(filename string, data []byte) (docs []targetDocument, skipped []error, err error) {
+	format := strings.TrimPrefix(strings.ToLower(path.Ext(filename)), ".")
+	if format == "yml" {
+		format = "yaml"
+	}
+	if format != "json" && format != "yaml" && format != "csv" {
+		trimmed := bytes.TrimSpace(data)
+		switch {
+		case bytes.HasPrefix(trimmed, []byte("[")):
+			format = "json"
+		case bytes.HasPrefix(trimmed, []byte("-")), isScrapeConfig(data):
+			format = "yaml"
+		default:
+			format = "csv"
+		}
+	}
+
+	switch {
+	case format == "json":
+		err = json.Unmarshal(data, &docs)
+	case format == "yaml" && isScrapeConfig(data):
+		format = "Prometheus config"
+		docs, skipped, err = decodeScrapeConfig(data)
+	case format == "yaml":
+		err = yaml.Unmarshal(data, &docs)
+	case format == "csv":
+		docs, skipped, err = decodeCSVTargets(data)
+	}
+	if err != nil {
+		return nil, nil, errorf("Could not parse %v: %v", format, err)
+	}
+	return docs, skipped, nil
+}
+
+// validateTargetDocuments normalizes URLs of the documents and returns
+// descriptions of invalid rows in the language.
+func validateTargetDocuments(lang string, docs []targetDocument) []string {
+	var problems []string
+	seen := map[string]int{}
+	for i := range docs {
+		row := i + 1
+		normalized, err := normalizeURL(docs[i].URL)
+		if err != nil {
+			problems = append(problems, translate(
+				lang, "row %v: invalid url %q: %v", row, docs[i].URL, translateError(lang, err)))
+			continue
+		}
+		docs[i].URL = normalized
+		if strings.TrimSpace(docs[i].Title) == "" {
+			docs[i].Title = normalized
+		}
+		tags, err := parseTags(strings.Join(docs[i].Tags, " "))
+		if err != nil {
+			problems = append(problems, translate(lang, "row %v: %v", row, translateError(lang, err)))
+			continue
+		}
+		docs[i].Tags = tags
+		if docs[i].Template != "" {
+			if _, err := parseTemplate(docs[i].Template); err != nil {
+				problems = append(problems, translate(
+					lang, "row %v: invalid template: %v", row, translateError(lang, err)))
+				continue
+			}
+		}
+		if prev, ok := seen[normalized]; ok {
+			problems = append(problems, translate(lang, "row %v: duplicates row %v", row, prev))
+		}
+		seen[normalized] = row
+	}
+	return problems
+}
+
+type importChange struct {
+	Before Record
+	After  Record
+}
+
+// importPlan is the result of comparing imported documents with the chat's
+// targets.
+type importPlan struct {
+	Added     []Record
+	Changed   []importChange
+	Unchanged []Record
+}
+
+// planImport matches the documents to the chat's targets by ID or, if it's
+// missing, by URL. Targets missing in the documents are left untouched.
+func (db *TargetsDB) planImport(chatID int64, docs []targetDocument) (importPlan, error) {
+	targs, err := db.GetCurrentTargets(chatID)
+	if err != nil {
+		return importPlan{}, err
+	}
+	byID := map[uint]Record{}
+	byURL := map[string]Record{}
+	for _, target := range targs {
+		byID[target.ID] = target
+		if normalized, err := normalizeURL(target.URL); err == nil {
+			byURL[normalized] = target
+		}
+	}
+
+	var plan importPlan
+	for _, doc := range docs {
+		existing, ok := byID[doc.ID]
+		if !ok {
+			existing, ok = byURL[doc.URL]
+		}
+		if !ok {
+			rec := Record{ChatID: chatID}
+			doc.apply(&rec)
+			plan.Added = append(plan.Added, rec)
+			continue
+		}
+
+		updated := existing
+		doc.apply(&updated)
+		if updated == existing {
+			plan.Unchanged = append(plan.Unchanged, existing)
+		} else {
+			plan.Changed = append(plan.Changed, importChange{existing, updated})
+		}
+	}
+	return plan, nil
+}
+
+func formatImportPlan(lang string, plan importPlan) string {
+	var lines []string
+	lines = append(lines, translate(
+		lang,
+		"<b>%v</b> to add, <b>%v</b> to change, <b>%v</b> unchanged.",
+		len(plan.Added), len(plan.Changed), len(plan.Unchanged)))
+
+	const maxListed = 30
+	listed := 0
+	for _, rec := range plan.Added {
+		if listed == maxListed {
+			break
+		}
+		lines = append(lines, fmt.Sprintf("+ %v (%v)", replaceHTML(rec.Title), replaceHTML(rec.URL)))
+		listed++
+	}
+	for _, change := range plan.Changed {
+		if listed == maxListed {
+			break
+		}
+		lines = append(lines, fmt.Sprintf(
+			"~ <b>%v</b>: %v (%v)",
+			change.Before.ID, replaceHTML(change.After.Title), replaceHTML(change.After.URL)))
+		listed++
+	}
+	if rest := len(plan.Added) + len(plan.Changed) - listed; rest > 0 {
+		lines = append(lines, translate(lang, "...and %v more", rest))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// applyImport saves the plan in a single transaction.
+func (db *TargetsDB) applyImport(plan *importPlan) error {
+	tx := db.DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+	txdb := &TargetsDB{DB: tx, TrustedChats: db.TrustedChats}
+
+	for i := range plan.Added {
+		if err := txdb.CreateTarget(&plan.Added[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, change := range plan.Changed {
+		if err := txdb.UpdateTarget(change.After); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// ImportReport is the outcome of ImportTargets.
+type ImportReport struct {
+	Added     int
+	Changed   int
+	Unchanged int
+	// Entries of the file, which couldn't be translated into targets.
+	Skipped []string
+}
+
+// ImportTargets reads targets from a file in any format accepted by /import
+// and saves them to the chat. The changes are recorded in the audit log under
+// the actor's name. If dryRun is true, the changes are only counted.
+func (db *TargetsDB) ImportTargets(chatID int64, actor, filename string, data []byte, dryRun bool) (ImportReport, error) {
+	docs, skipped, err := decodeTargetDocuments(filename, data)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	var skippedStrings []string
+	for _, reason := range skipped {
+		skippedStrings = append(skippedStrings, reason.Error())
+	}
+	if problems := validateTargetDocuments(LanguageEnglish, docs); len(problems) > 0 {
+		return ImportReport{}, errorf("The file has errors:\n%v", strings.Join(problems, "\n"))
+	}
+
+	plan, err := db.planImport(chatID, docs)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	if !dryRun {
+		if err := db.applyImport(&plan); err != nil {
+			return ImportReport{}, err
+		}
+
+		var entries []AuditEntry
+		for i := range plan.Added {
+			entries = append(entries, newAuditEntry(chatID, ActionCreate, nil, &plan.Added[i]))
+		}
+		for i := range plan.Changed {
+			entries = append(entries, newAuditEntry(chatID, ActionEdit, &plan.Changed[i].Before, &plan.Changed[i].After))
+		}
+		for _, entry := range entries {
+			entry.Username = actor
+			if err := db.AddAuditEntry(entry); err != nil {
+				return ImportReport{}, err
+			}
+		}
+	}
+	return ImportReport{
+		Added:     len(plan.Added),
+		Changed:   len(plan.Changed),
+		Unchanged: len(plan.Unchanged),
+		Skipped:   skippedStrings,
+	}, nil
+}
+
+func (b *Bot) sendExport(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	format := strings.ToLower(strings.TrimSpace(message.CommandArguments()))
+	if format == "" {
+		format = "json"
+	}
+
+	targs, err := b.DB.GetCurrentTargets(message.Chat.ID)
+	if err != nil {
+		b.SendMessage(
+			message.Chat.ID,
+			translate(
+				lang,
+				"Error while retrieving the targets, please contact the administrator: %v",
+				b.AdminNickname))
+		return
+	}
+	docs := []targetDocument{}
+	for _, target := range targs {
+		docs = append(docs, newTargetDocument(target))
+	}
+
+	data, err := encodeTargetDocuments(format, docs)
+	if err != nil {
+		b.SendMessage(message.Chat.ID, replaceHTML(translateError(lang, err)))
+		return
+	}
+
+	doc := tgbotapi.NewDocumentUpload(message.Chat.ID, tgbotapi.FileBytes{
+		Name:  "targets." + format,
+		Bytes: data,
+	})
+	doc.Caption = translate(lang, "%v targets", len(docs))
+	if _, err := b.TgBot.Send(doc); err != nil {
+		fmt.Println(err)
+	}
+}
+
+func (b *Bot) downloadDocument(doc *tgbotapi.Document) ([]byte, error) {
+	if doc.FileSize > maxImportSize {
+		return nil, errorf("file is too large")
+	}
+	fileURL, err := b.TgBot.GetFileDirectURL(doc.FileID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.Get(fileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errorf("could not download the file: %v", resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, maxImportSize))
+}
+
+type importTargets struct {
+	// Validated documents waiting for confirmation.
+	Docs []targetDocument
+	bot  *Bot
+}
+
+func (t *importTargets) Kind() string { return "import" }
+
+func (t *importTargets) ContinueDialog(stepNumber int, update tgbotapi.Update, bot *tgbotapi.BotAPI) (int, bool) {
+	message := update.Message
+	lang := t.bot.chatLanguage(message.Chat.ID)
+	if stepNumber == 1 {
+		t.bot.SendDialogMessage(
+			message,
+			translate(
+				lang,
+				"Send a JSON, CSV or YAML file with the targets, e.g. the one made by /export. "+
+					"Send /cancel if you've changed your mind."))
+		return 2, true
+	}
+	if stepNumber == 2 {
+		if message.Document == nil {
+			t.bot.SendDialogMessage(message, translate(lang, "Please send the targets as a file"))
+			return 2, true
+		}
+		data, err := t.bot.downloadDocument(message.Document)
+		if err != nil {
+			fmt.Println(err)
+			t.bot.SendDialogMessage(message, translate(lang, "Could not download the file, please try again"))
+			return 2, true
+		}
+		docs, skipped, err := decodeTargetDocuments(message.Document.FileName, data)
+		if err != nil {
+			t.bot.SendDialogMessage(message, translate(lang, "%v. Please send a fixed file", replaceHTML(translateError(lang, err))))
+			return 2, true
+		}
+		skippedText := ""
+		if len(skipped) > 0 {
+			const maxListed = 20
+			var reasons []string
+			for i, reason := range skipped {
+				if i == maxListed {
+					reasons = append(reasons, translate(lang, "...and %v more", len(skipped)-maxListed))
+					break
+				}
+				reasons = append(reasons, translateError(lang, reason))
+			}
+			skippedText = "\n\n" + translate(lang, "Could not translate:") + "\n" +
+				replaceHTML(strings.Join(reasons, "\n"))
+		}
+		if len(docs) == 0 {
+			t.bot.SendDialogMessage(
+				message,
+				translate(lang, "The file contains no targets.")+skippedText+
+					"\n\n"+translate(lang, "Please send another file"))
+			return 2, true
+		}
+		if problems := validateTargetDocuments(lang, docs); len(problems) > 0 {
+			t.bot.SendDialogMessage(
+				message,
+				translate(lang, "The file has errors:")+"\n"+replaceHTML(strings.Join(problems, "\n"))+
+					"\n\n"+translate(lang, "Please send a fixed file"))
+			return 2, true
+		}
+
+		plan, err := t.bot.DB.planImport(message.Chat.ID, docs)
+		if err != nil {
+			t.bot.SendMessage(
+				message.Chat.ID,
+				translate(
+					lang,
+					"Error while retrieving the targets, please contact the administrator: %v",
+					t.bot.AdminNickname))
+			return 0, false
+		}
+		if len(plan.Added) == 0 && len(plan.Changed) == 0 {
+			t.bot.SendMessage(message.Chat.ID, translate(lang, "All the targets are up to date, nothing to import"))
+			return 0, false
+		}
+		t.Docs = docs
+		t.bot.SendDialogMessage(
+			message,
+			formatImportPlan(lang, plan)+skippedText+"\n\n"+translate(lang, "Send <b>yes</b> to apply the changes."))
+		return 3, true
+	}
+	if stepNumber == 3 {
+		answer := strings.ToLower(strings.TrimSpace(message.Text))
+		if answer != "yes" && answer != translate(lang, "yes") {
+			t.bot.SendMessage(message.Chat.ID, translate(lang, "Import has been canceled"))
+			return 0, false
+		}
+
+		// The targets might have changed since the preview.
+		plan, err := t.bot.DB.planImport(message.Chat.ID, t.Docs)
+		if err == nil {
+			err = t.bot.DB.applyImport(&plan)
+		}
+		if err != nil {
+			fmt.Println(err)
+			t.bot.SendMessage(
+				message.Chat.ID,
+				translate(
+					lang,
+					"Error while importing the targets, please contact the administrator: %v",
+					t.bot.AdminNickname))
+			return 0, false
+		}
+
+		for i := range plan.Added {
+			t.bot.audit(message, ActionCreate, nil, &plan.Added[i])
+		}
+		for i := range plan.Changed {
+			t.bot.audit(message, ActionEdit, &plan.Changed[i].Before, &plan.Changed[i].After)
+		}
+		t.bot.SendMessage(message.Chat.ID, translate(
+			lang,
+			"Imported: %v added, %v changed", len(plan.Added), len(plan.Changed)))
+		return 0, false
+	}
+	return 0, false
+}
@@ -0,0 +1,119 @@
+package telegrambot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTargetDocumentsRoundTrip(t *testing.T) {
+	docs := []targetDocument{
+		{ID: 1, Title: "Site, main", URL: "https://example.com/", Critical: true, Tags: []string{"prod", "web"}},
+		{ID: 2, Title: "API", URL: "https://api.example.com/health", Paused: true},
+		{Title: "New", URL: "http://example.org", Template: "{{.Emoji}} <b>{{.Target.Title}}</b>"},
+	}
+	for _, format := range []string{"json", "csv", "yaml"} {
+		data, err := encodeTargetDocuments(format, docs)
+		if err != nil {
+			t.Fatalf("%v: %v", format, err)
+		}
+		// The format is detected by the content if the extension is unknown.
+		for _, filename := range []string{"targets." + format, "targets"} {
+			decoded, skipped, err := decodeTargetDocuments(filename, data)
+			if err != nil || len(skipped) > 0 {
+				t.Fatalf("%v: %v, skipped %v", filename, err, skipped)
+			}
+			for i := range decoded {
+				if len(decoded[i].Tags) == 0 {
+					decoded[i].Tags = nil
+				}
+			}
+			if !reflect.DeepEqual(decoded, docs) {
+				t.Errorf("%v of %v: got %+v, want %+v", filename, format, decoded, docs)
+			}
+		}
+	}
+
+	if _, err := encodeTargetDocuments("xml", docs); err == nil {
+		t.Error("Unknown format is encoded")
+	}
+}
+
+func TestDecodeTargetDocumentsErrors(t *testing.T) {
+	cases := map[string]string{
+		"targets.json": `[{"url": `,
+		"targets.yaml": "- url: [",
+		"targets.csv":  "title,address_of_site\nA,example.com\n",
+	}
+	for filename, data := range cases {
+		if docs, _, err := decodeTargetDocuments(filename, []byte(data)); err == nil {
+			t.Errorf("%v is decoded: %+v", filename, docs)
+		}
+	}
+
+	docs, _, err := decodeTargetDocuments("targets.csv", []byte("id,title,url\nx,A,example.com\n"))
+	if err == nil {
+		t.Errorf("Invalid id is decoded: %+v", docs)
+	}
+}
+
+func TestValidateTargetDocuments(t *testing.T) {
+	docs := []targetDocument{
+		{URL: "Example.com", Tags: []string{"Prod"}},
+		{URL: "ftp://example.com"},
+		{URL: "http://example.com"},
+		{URL: "example.net", Tags: []string{"bad tag!"}},
+		{URL: "example.org", Template: "{{.Nope"},
+	}
+	problems := validateTargetDocuments(LanguageEnglish, docs)
+
+	if docs[0].URL != "http://example.com" || docs[0].Title != "http://example.com" {
+		t.Errorf("Document is not normalized: %+v", docs[0])
+	}
+	if !reflect.DeepEqual(docs[0].Tags, []string{"prod"}) {
+		t.Errorf("Tags are not normalized: %v", docs[0].Tags)
+	}
+	want := []string{"row 2: invalid url", "row 3: duplicates row 1", "row 4:", "row 5: invalid template"}
+	if len(problems) != len(want) {
+		t.Fatalf("Problems: %q, want %v", problems, len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(problems[i], prefix) {
+			t.Errorf("Problem %v is %q, want %q...", i, problems[i], prefix)
+		}
+	}
+}
+
+func TestPlanImport(t *testing.T) {
+	b, rec := newRoutingBot(t)
+	other := &Record{ChatID: 10, Title: "Other", URL: "http://example.org/"}
+	if err := b.DB.CreateTarget(other); err != nil {
+		t.Fatal(err)
+	}
+	foreign := &Record{ChatID: 99, Title: "Foreign", URL: "http://example.net/"}
+	if err := b.DB.CreateTarget(foreign); err != nil {
+		t.Fatal(err)
+	}
+
+	docs := []targetDocument{
+		// Matched by ID.
+		{ID: rec.ID, Title: "Renamed", URL: rec.URL, Tags: rec.TagList()},
+		// Matched by URL.
+		{Title: other.Title, URL: other.URL},
+		// Targets of other chats are not matched.
+		{ID: foreign.ID, Title: foreign.Title, URL: foreign.URL},
+	}
+	plan, err := b.DB.planImport(10, docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Changed) != 1 || plan.Changed[0].After.Title != "Renamed" || plan.Changed[0].Before.Title != "Site" {
+		t.Errorf("Unexpected changes: %+v", plan.Changed)
+	}
+	if len(plan.Unchanged) != 1 || plan.Unchanged[0].ID != other.ID {
+		t.Errorf("Unexpected unchanged targets: %+v", plan.Unchanged)
+	}
+	if len(plan.Added) != 1 || plan.Added[0].ChatID != 10 || plan.Added[0].ID != 0 {
+		t.Errorf("Unexpected added targets: %+v", plan.Added)
+	}
+}
@@ -0,0 +1,223 @@
+package telegrambot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Languages of the bot's messages.
+const (
+	LanguageEnglish = "en"
+	LanguageRussian = "ru"
+)
+
+// catalogs map messages of the bot, which are written in English and are
+// often format strings, to their translations. Messages missing from
+// a catalog are sent in English.
+var catalogs = map[string]map[string]string{
+	LanguageRussian: russianMessages,
+}
+
+// normalizeLanguage maps a language code like "ru" or "en-US" to one of
+// Language* constants. It returns an empty string for unsupported languages.
+func normalizeLanguage(code string) string {
+	code = strings.ToLower(code)
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if code == LanguageEnglish {
+		return code
+	}
+	if _, ok := catalogs[code]; ok {
+		return code
+	}
+	return ""
+}
+
+// translate returns the message in the language, formatted with the args if
+// there are any.
+func translate(lang, message string, args ...interface{}) string {
+	if translated, ok := catalogs[lang][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
+
+// localizedError is an error shown to users, which can be translated.
+type localizedError struct {
+	format string
+	args   []interface{}
+}
+
+// errorf is like fmt.Errorf, but the error is translated when shown with
+// translateError.
+func errorf(format string, args ...interface{}) error {
+	return &localizedError{format: format, args: args}
+}
+
+func (e *localizedError) Error() string {
+	return fmt.Sprintf(e.format, e.args...)
+}
+
+// translateError returns the error's message in the language if the error
+// was created with errorf.
+func translateError(lang string, err error) string {
+	if le, ok := err.(*localizedError); ok {
+		return translate(lang, le.format, le.args...)
+	}
+	return err.Error()
+}
+
+// languageOf returns the language of messages to the chat with
+// the settings: the chosen one or else the detected one.
+func (b *Bot) languageOf(settings ChatSettings) string {
+	if settings.Language != "" {
+		return settings.Language
+	}
+	b.languagesMu.Lock()
+	defer b.languagesMu.Unlock()
+	if b.languages == nil {
+		b.languages = make(map[int64]string)
+	}
+	lang, ok := b.languages[settings.ChatID]
+	if !ok {
+		var err error
+		lang, err = b.DB.GetChatLanguage(settings.ChatID)
+		if err != nil {
+			fmt.Println(err)
+			return LanguageEnglish
+		}
+		b.languages[settings.ChatID] = lang
+	}
+	if lang == "" {
+		return LanguageEnglish
+	}
+	return lang
+}
+
+// chatLanguage returns the language of messages to the chat.
+func (b *Bot) chatLanguage(chatID int64) string {
+	settings, err := b.getChatSettings(chatID)
+	if err != nil {
+		fmt.Println(err)
+	}
+	settings.ChatID = chatID
+	return b.languageOf(settings)
+}
+
+// tr translates the message into the chat's language.
+func (b *Bot) tr(chatID int64, message string, args ...interface{}) string {
+	return translate(b.chatLanguage(chatID), message, args...)
+}
+
+// detectLanguage remembers the language of the message's author's Telegram
+// as the language of the chat, which is used unless the chat has chosen one
+// in /settings.
+func (b *Bot) detectLanguage(message *tgbotapi.Message) {
+	if message.From == nil || message.From.LanguageCode == "" {
+		return
+	}
+	lang := normalizeLanguage(message.From.LanguageCode)
+	if lang == "" {
+		lang = LanguageEnglish
+	}
+
+	b.languagesMu.Lock()
+	defer b.languagesMu.Unlock()
+	if b.languages == nil {
+		b.languages = make(map[int64]string)
+	}
+	if known, ok := b.languages[message.Chat.ID]; ok && known == lang {
+		return
+	}
+	if err := b.DB.SetChatLanguage(message.Chat.ID, lang); err != nil {
+		fmt.Println(err)
+		return
+	}
+	b.languages[message.Chat.ID] = lang
+}
+
+// pluralForms are forms of nouns used with numbers: for one and for many in
+// English; for one, few and many in Russian.
+var pluralForms = map[string]map[string][]string{
+	LanguageEnglish: {
+		"hour": {"hour", "hours"},
+		"day":  {"day", "days"},
+	},
+	LanguageRussian: {
+		"hour": {"час", "часа", "часов"},
+		"day":  {"день", "дня", "дней"},
+	},
+}
+
+// formatCount formats the number with the noun in the right form, e.g.
+// "5 days".
+func formatCount(lang string, n int64, noun string) string {
+	forms, ok := pluralForms[lang][noun]
+	if !ok {
+		forms = pluralForms[LanguageEnglish][noun]
+	}
+	form := forms[len(forms)-1]
+	switch {
+	case len(forms) == 2 && n == 1:
+		form = forms[0]
+	case len(forms) == 3 && n%10 == 1 && n%100 != 11:
+		form = forms[0]
+	case len(forms) == 3 && n%10 >= 2 && n%10 <= 4 && (n%100 < 12 || n%100 > 14):
+		form = forms[1]
+	}
+	return fmt.Sprintf("%v %v", n, form)
+}
+
+// timeLayouts map layouts used by the bot to the layouts of other languages.
+var timeLayouts = map[string]map[string]string{
+	LanguageRussian: {
+		"2006-01-02":              "02.01.2006",
+		"2006-01-02 15:04":        "02.01.2006 15:04",
+		"2006-01-02 15:04:05":     "02.01.2006 15:04:05",
+		"2006-01-02 15:04:05 MST": "02.01.2006 15:04:05 MST",
+		"01-02":                   "02.01",
+	},
+}
+
+// formatTime formats the time with the layout customary for the language.
+func formatTime(lang string, t time.Time, layout string) string {
+	if localized, ok := timeLayouts[lang][layout]; ok {
+		layout = localized
+	}
+	return t.Format(layout)
+}
+
+// durationUnits map units of time.Duration.String to units of other
+// languages.
+var durationUnits = map[string]map[string]string{
+	LanguageRussian: {
+		"h": "ч", "m": "мин", "s": "с", "ms": "мс", "µs": "мкс", "ns": "нс",
+	},
+}
+
+var durationPartRegexp = regexp.MustCompile(`([0-9.]+)(h|ms|µs|ns|m|s)`)
+
+// localizeDuration formats the duration like time.Duration.String, but with
+// units of the language, e.g. "1 ч 30 мин 0 с" instead of "1h30m0s".
+func localizeDuration(lang string, d time.Duration) string {
+	units, ok := durationUnits[lang]
+	if !ok {
+		return d.String()
+	}
+	var parts []string
+	for _, match := range durationPartRegexp.FindAllStringSubmatch(d.String(), -1) {
+		parts = append(parts, strings.Replace(match[1], ".", ",", 1)+" "+units[match[2]])
+	}
+	if strings.HasPrefix(d.String(), "-") {
+		return "-" + strings.Join(parts, " ")
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,320 @@
+package telegrambot
+
+// russianMessages is the Russian catalog, see catalogs.
+var russianMessages = map[string]string{
+	// Common replies.
+	"Invalid ID":                                         "Неверный ID",
+	"Invalid ID, please try again":                       "Неверный ID, попробуйте ещё раз",
+	"Invalid ID or tag":                                  "Неверный ID или тег",
+	"No target with such ID found":                       "Цель с таким ID не найдена",
+	"No targets! Use /add to add one.":                   "Целей нет! Добавьте цель командой /add.",
+	"You have no targets added! Use /add to add one":     "У вас нет целей! Добавьте цель командой /add",
+	"No targets with this tag":                           "Нет целей с этим тегом",
+	"Action has been canceled":                           "Действие отменено",
+	"Action has been canceled due to inactivity":         "Действие отменено из-за бездействия",
+	"No action in process":                               "Нет действия, которое можно отменить",
+	"Sorry, you are not allowed to do this in this chat": "Извините, вам нельзя делать это в этом чате",
+	"Usage:":         "Использование:",
+	"yes":            "да",
+	"...and %v more": "...и ещё %v",
+	"Hi!\nI'm a bot which can monitor sites' availability and notify you when a site goes down or up again.\n": "Привет!\nЯ бот, который следит за доступностью сайтов и сообщает, когда сайт падает или снова начинает работать.\n",
+
+	"Error while adding the target, please contact the administrator: %v":              "Ошибка при добавлении цели, обратитесь к администратору: %v",
+	"Error while building the graph, please contact the administrator: %v":             "Ошибка при построении графика, обратитесь к администратору: %v",
+	"Error while building the report, please contact the administrator: %v":            "Ошибка при составлении отчёта, обратитесь к администратору: %v",
+	"Error while changing the template, please contact the administrator: %v":          "Ошибка при изменении шаблона, обратитесь к администратору: %v",
+	"Error while checking your permissions, please contact the administrator: %v":      "Ошибка при проверке ваших прав, обратитесь к администратору: %v",
+	"Error while deleting the target, please contact the administrator: %v":            "Ошибка при удалении цели, обратитесь к администратору: %v",
+	"Error while editing the target, please contact the administrator: %v":             "Ошибка при изменении цели, обратитесь к администратору: %v",
+	"Error while importing the targets, please contact the administrator: %v":          "Ошибка при импорте целей, обратитесь к администратору: %v",
+	"Error while managing the channels, please contact the administrator: %v":          "Ошибка при настройке каналов, обратитесь к администратору: %v",
+	"Error while restoring the target, please contact the administrator: %v":           "Ошибка при восстановлении цели, обратитесь к администратору: %v",
+	"Error while retrieving the audit log, please contact the administrator: %v":       "Ошибка при получении журнала изменений, обратитесь к администратору: %v",
+	"Error while retrieving the hooks, please contact the administrator: %v":           "Ошибка при получении запусков хуков, обратитесь к администратору: %v",
+	"Error while retrieving the settings, please contact the administrator: %v":        "Ошибка при получении настроек, обратитесь к администратору: %v",
+	"Error while retrieving the target's status, please contact the administrator: %v": "Ошибка при получении статуса цели, обратитесь к администратору: %v",
+	"Error while retrieving the targets, please contact the administrator: %v":         "Ошибка при получении целей, обратитесь к администратору: %v",
+	"Error while saving the settings, please contact the administrator: %v":            "Ошибка при сохранении настроек, обратитесь к администратору: %v",
+	"Error while sharing the target, please contact the administrator: %v":             "Ошибка при открытии доступа к цели, обратитесь к администратору: %v",
+	"Error while subscribing to the target, please contact the administrator: %v":      "Ошибка при подписке на цель, обратитесь к администратору: %v",
+
+	// Statuses, see monitor.StatusType.
+	"OK":                "OK",
+	"Generic Error":     "Ошибка",
+	"Timeout":           "Тайм-аут",
+	"URL Parsing Error": "Ошибка разбора URL",
+	"DNS Error":         "Ошибка DNS",
+	"HTTP Error":        "Ошибка HTTP",
+	"Unknown":           "Неизвестно",
+
+	// Notifications, see defaultTemplateText.
+	"URL":             "URL",
+	"Tags":            "Теги",
+	"Time":            "Время",
+	"Previous status": "Предыдущий статус",
+	"Response time":   "Время ответа",
+	"Error msg":       "Ошибка",
+	"HTTP Status":     "HTTP-статус",
+
+	// Durations, see formatDuration.
+	"%vs":     "%v с",
+	"%vm":     "%v мин",
+	"%vh":     "%v ч",
+	"%vh %vm": "%v ч %v мин",
+
+	// Adding, editing and deleting targets.
+	"Enter the title for the target. Send /cancel if you've changed your mind.": "Введите название цели. Отправьте /cancel, если передумали.",
+	"Enter the url for the target":                                                                                 "Введите URL цели",
+	"Invalid url: %v. Please try again":                                                                            "Неверный URL: %v. Попробуйте ещё раз",
+	"This url is already monitored as <b>%v</b> (ID %v). Please enter another url":                                 "Этот URL уже отслеживается как <b>%v</b> (ID %v). Введите другой URL",
+	"%v Test poll of %v failed: <b>%v</b> (%v)\n\nSend <b>yes</b> to add the target anyway, or enter another url.": "%v Пробный опрос %v не удался: <b>%v</b> (%v)\n\nОтправьте <b>да</b>, чтобы всё равно добавить цель, или введите другой URL.",
+	"%v Test poll of %v: <b>%v</b> (%v ms)":                                                                        "%v Пробный опрос %v: <b>%v</b> (%v мс)",
+	"Test poll of %v is in progress, please wait or send /cancel":                                                  "Идёт пробный опрос %v, подождите или отправьте /cancel",
+	"Target was successfully added":                                                                                "Цель добавлена",
+	"Enter the <b>ID</b> of a target to delete it. Send /cancel if you've changed your mind.":                      "Введите <b>ID</b> цели, чтобы удалить её. Отправьте /cancel, если передумали.",
+	"Target was successfully deleted!":                                                                             "Цель удалена!",
+	"Enter the <b>ID</b> of a target to edit it. Send /cancel if you've changed your mind.":                        "Введите <b>ID</b> цели, чтобы изменить её. Отправьте /cancel, если передумали.",
+	"Enter the new title for the target or send <b>-</b> to keep <i>%v</i>":                                        "Введите новое название цели или отправьте <b>-</b>, чтобы оставить <i>%v</i>",
+	"Enter the new url for the target or send <b>-</b> to keep the current one":                                    "Введите новый URL цели или отправьте <b>-</b>, чтобы оставить текущий",
+	"Target was successfully edited":                                                                               "Цель изменена",
+	"Enter the <b>ID</b> of a target or a tag to pause the targets. Send /cancel if you've changed your mind.":     "Введите <b>ID</b> цели или тег, чтобы приостановить цели. Отправьте /cancel, если передумали.",
+	"Enter the <b>ID</b> of a target or a tag to resume the targets. Send /cancel if you've changed your mind.":    "Введите <b>ID</b> цели или тег, чтобы возобновить цели. Отправьте /cancel, если передумали.",
+	"Target was paused, use /resume to continue monitoring it":                                                     "Цель приостановлена, возобновите наблюдение командой /resume",
+	"Target was resumed": "Наблюдение за целью возобновлено",
+	"%v targets with tag %v were paused, use /resume %v to continue monitoring them":                         "Приостановлено целей с тегом %[2]v: %[1]v, возобновите наблюдение командой /resume %[3]v",
+	"%v targets with tag %v were resumed":                                                                    "Возобновлено целей с тегом %[2]v: %[1]v",
+	"Enter the <b>ID</b> of a target to toggle its critical flag. Send /cancel if you've changed your mind.": "Введите <b>ID</b> цели, чтобы переключить её критичность. Отправьте /cancel, если передумали.",
+	"Target is now critical, its notifications ignore quiet hours":                                           "Цель теперь критичная, её уведомления не учитывают тихие часы",
+	"Target is no longer critical":                                                                           "Цель больше не критичная",
+	"Enter the <b>ID</b> of a target to restore it. Send /cancel if you've changed your mind.":               "Введите <b>ID</b> цели, чтобы восстановить её. Отправьте /cancel, если передумали.",
+	"<b>%v</b>: <a href=\"%v\">%v</a> (deleted %v)":                                                          "<b>%v</b>: <a href=\"%v\">%v</a> (удалена %v)",
+	"There are no recently deleted targets":                                                                  "Недавно удалённых целей нет",
+	"No deleted target with such ID found":                                                                   "Удалённая цель с таким ID не найдена",
+	"Target was successfully restored!":                                                                      "Цель восстановлена!",
+
+	// URLs, see normalizeURL.
+	"URL is empty":                           "URL пустой",
+	"URL must not contain spaces":            "URL не должен содержать пробелов",
+	"URL could not be parsed":                "Не удалось разобрать URL",
+	"only http and https URLs are supported": "поддерживаются только URL с http и https",
+	"URL has no host":                        "В URL нет хоста",
+	"invalid domain name":                    "неверное доменное имя",
+	"domain name must contain a dot":         "доменное имя должно содержать точку",
+
+	// Tags.
+	"Invalid tag %q": "Неверный тег %q",
+	"Invalid tag %q: only letters, digits, - and _ are allowed": "Неверный тег %q: допустимы только буквы, цифры, - и _",
+	"Usage: /%v ID tag...":   "Использование: /%v ID тег...",
+	"Target has no tags now": "У цели больше нет тегов",
+	"Target's tags: %v":      "Теги цели: %v",
+
+	// Target list.
+	"Untagged":                  "Без тегов",
+	" (shared)":                 " (общая)",
+	"%v: paused":                "%v: приостановлена",
+	"%v: %v %v (%v ms)":         "%v: %v %v (%v мс)",
+	"Only one tag can be given": "Можно указать только один тег",
+	"Usage: /targets [tag] [name|status|latency] [failing]": "Использование: /targets [тег] [name|status|latency] [failing]",
+	"All targets are up":                  "Все цели работают",
+	"<b>%v of %v targets are failing</b>": "<b>Не работает целей: %v из %v</b>",
+
+	// Checks.
+	"Usage: /check URL":                               "Использование: /check URL",
+	"Error while parsing url":                         "Ошибка при разборе URL",
+	"Sorry, this host cannot be checked":              "Извините, этот хост нельзя проверить",
+	"Too many checks, please try again in %v seconds": "Слишком много проверок, попробуйте ещё раз через %v с",
+
+	// Settings.
+	"Settings of this chat:": "Настройки этого чата:",
+	"nobody":                 "никто",
+	"Please send the name of a setting and its value": "Отправьте название настройки и её значение",
+	"Invalid user ID, please try again":               "Неверный ID пользователя, попробуйте ещё раз",
+	"Allowlist was successfully updated":              "Список разрешённых пользователей обновлён",
+	"Unknown setting %q, please try again":            "Неизвестная настройка %q, попробуйте ещё раз",
+	"<b>%v</b> is now set to %v":                      "<b>%v</b> теперь имеет значение %v",
+	"Send the name of a setting and its new value, e.g. <code>policy admins</code>. Use <code>allow USER_ID</code> and <code>disallow USER_ID</code> to edit the allowlist or reply to a member's message with <code>/settings allow</code>. Send /cancel if you've changed your mind.": "Отправьте название настройки и её новое значение, например <code>policy admins</code>. Используйте <code>allow USER_ID</code> и <code>disallow USER_ID</code>, чтобы изменить список разрешённых пользователей, или ответьте на сообщение участника командой <code>/settings allow</code>. Отправьте /cancel, если передумали.",
+	"who can add, edit and delete targets: everyone, admins or allowlist":                          "кто может добавлять, изменять и удалять цели: everyone (все), admins (администраторы) или allowlist (список разрешённых)",
+	"notify about the first status of a new target even if it's OK":                                "сообщать о первом статусе новой цели, даже если он OK",
+	"notify when a target goes up again":                                                           "сообщать, когда цель снова работает",
+	"send recovery notifications without sound":                                                    "отправлять уведомления о восстановлении без звука",
+	"include response time, error and HTTP status into notifications":                              "включать в уведомления время ответа, ошибку и HTTP-статус",
+	"language of the bot's messages: en, ru or auto (the language of the members' Telegram)":       "язык сообщений бота: en, ru или auto (язык Telegram участников)",
+	"time zone of displayed times, e.g. Europe/Moscow":                                             "часовой пояс отображаемого времени, например Europe/Moscow",
+	"how detailed notifications are: short, normal or full":                                        "подробность уведомлений: short, normal или full",
+	"daily interval like 23:00-07:00 when only critical targets notify loudly, or off":             "ежедневный интервал вроде 23:00-07:00, когда со звуком уведомляют только критичные цели, или off",
+	"what to do with notifications during quiet hours: silent or queue (send a digest afterwards)": "что делать с уведомлениями в тихие часы: silent (без звука) или queue (прислать сводку после)",
+	"when to send uptime reports: daily HH:MM, weekly mon HH:MM or off":                            "когда присылать отчёты о доступности: daily ЧЧ:ММ, weekly mon ЧЧ:ММ или off",
+	"Invalid value %q, use on or off":                                                              "Неверное значение %q, используйте on или off",
+	"Unknown policy %q, use everyone, admins or allowlist":                                         "Неизвестная политика %q, используйте everyone, admins или allowlist",
+	"Unknown language %q, use en, ru or auto":                                                      "Неизвестный язык %q, используйте en, ru или auto",
+	"Unknown time zone %q":                                          "Неизвестный часовой пояс %q",
+	"Unknown verbosity %q, use short, normal or full":               "Неизвестная подробность %q, используйте short, normal или full",
+	"Unknown quiet mode %q, use silent or queue":                    "Неизвестный режим тихих часов %q, используйте silent или queue",
+	"Invalid time %q, use HH:MM":                                    "Неверное время %q, используйте ЧЧ:ММ",
+	"Invalid interval %q, use HH:MM-HH:MM":                          "Неверный интервал %q, используйте ЧЧ:ММ-ЧЧ:ММ",
+	"Invalid schedule %q, use e.g. daily 09:00 or weekly mon 09:00": "Неверное расписание %q, используйте, например, daily 09:00 или weekly mon 09:00",
+
+	// Quiet hours.
+	"<b>Status changes during quiet hours:</b>": "<b>Изменения статусов в тихие часы:</b>",
+	"<b>Still down:</b> %v":                     "<b>Всё ещё не работают:</b> %v",
+
+	// Reports and graphs.
+	"Invalid period %q, use e.g. 24h, 7d or 2w":                                 "Неверный период %q, используйте, например, 24h, 7d или 2w",
+	"History is only kept for %v":                                               "История хранится только %v",
+	"<b>Uptime report for the last %v</b>":                                      "<b>Отчёт о доступности за последние %v</b>",
+	"%v: no data":                                                               "%v: нет данных",
+	"%v: %.2f%% uptime, %v incidents, %v down, %v ms avg":                       "%v: доступность %.2f%%, инцидентов: %v, простой %v, в среднем %v мс",
+	"Usage: /graph ID [24h|7d|30d]":                                             "Использование: /graph ID [24h|7d|30d]",
+	"No data for this period yet":                                               "За этот период данных пока нет",
+	"%v, last %v: response time, ms; downtime in red. %.2f%% uptime, %v ms avg": "%v, последние %v: время ответа, мс; простой выделен красным. Доступность %.2f%%, в среднем %v мс",
+
+	// Audit log.
+	"No changes were made to the targets yet": "Цели пока не изменялись",
+	"added":             "добавил(а)",
+	"edited":            "изменил(а)",
+	"deleted":           "удалил(а)",
+	"paused":            "приостановил(а)",
+	"resumed":           "возобновил(а)",
+	"restored":          "восстановил(а)",
+	"title: %v → %v":    "название: %v → %v",
+	"url: %v → %v":      "URL: %v → %v",
+	"tags: %v → %v":     "теги: %v → %v",
+	"critical: %v → %v": "критичная: %v → %v",
+	"template changed":  "шаблон изменён",
+
+	// Sharing.
+	"Usage: /share ID":   "Использование: /share ID",
+	"Usage: /unshare ID": "Использование: /unshare ID",
+	"Use these links to receive notifications about <b>%v</b> in another chat.\n\nPrivate chat: https://t.me/%v?start=%v\nGroup: https://t.me/%v?startgroup=%v\n\nOnly this chat can edit or delete the target. Use /unshare %v to revoke the links.": "Используйте эти ссылки, чтобы получать уведомления о <b>%v</b> в другом чате.\n\nЛичный чат: https://t.me/%v?start=%v\nГруппа: https://t.me/%v?startgroup=%v\n\nИзменять и удалять цель может только этот чат. Отзовите ссылки командой /unshare %v.",
+	"Share links of the target were revoked":                                                              "Ссылки на цель отозваны",
+	"This link is invalid or has been revoked":                                                            "Эта ссылка неверна или была отозвана",
+	"This target belongs to this chat already":                                                            "Эта цель уже принадлежит этому чату",
+	"This chat is now subscribed to <b>%v</b> (%v). Use /unsubscribe %v to stop receiving notifications.": "Этот чат подписан на <b>%v</b> (%v). Отпишитесь командой /unsubscribe %v, чтобы больше не получать уведомления.",
+	"Usage: /unsubscribe ID":                                                                              "Использование: /unsubscribe ID",
+	"This chat has no subscriptions":                                                                      "У этого чата нет подписок",
+	"This chat is not subscribed to such target":                                                          "Этот чат не подписан на такую цель",
+	"Unsubscribed": "Подписка отменена",
+	"<b>%v</b> (%v) was deleted by the chat owning it, notifications about it are stopped": "<b>%v</b> (%v) удалена чатом-владельцем, уведомления о ней прекращены",
+
+	// Channels.
+	"/channels - list the chat's channels":                               "/channels - список каналов чата",
+	"/channels log - show latest deliveries":                             "/channels log - последние доставки",
+	"No channels, notifications are only sent here.":                     "Каналов нет, уведомления приходят только сюда.",
+	"Unknown channel kind.":                                              "Неизвестный вид канала.",
+	"%v\n\nUsage: /channels add %v %v [target=ID]":                       "%v\n\nИспользование: /channels add %v %v [target=ID]",
+	"Channel was added: ":                                                "Канал добавлен: ",
+	"Usage: /channels delete ID":                                         "Использование: /channels delete ID",
+	"Channel was deleted":                                                "Канал удалён",
+	"Nothing was delivered yet":                                          "Пока ничего не доставлено",
+	" (target %v only)":                                                  " (только цель %v)",
+	", with secret":                                                      ", с секретом",
+	"%v %v %v, target %v, incident %v, attempt %v: %v":                   "%v %v %v, цель %v, инцидент %v, попытка %v: %v",
+	"Expected URL and optional secret":                                   "Ожидается URL и необязательный секрет",
+	"Expected URL of an incoming webhook of Slack or Mattermost":         "Ожидается URL входящего вебхука Slack или Mattermost",
+	"Matrix is not configured on this bot":                               "Matrix не настроен в этом боте",
+	"Only the bot's superusers can add Matrix channels":                  "Добавлять каналы Matrix могут только суперпользователи бота",
+	"Expected room ID like !abcdef:matrix.org":                           "Ожидается ID комнаты вида !abcdef:matrix.org",
+	"Expected integration key of the service to page":                    "Ожидается ключ интеграции сервиса для вызова",
+	"Expected URL of the topic and optional access token":                "Ожидается URL топика и необязательный токен доступа",
+	"The URL must contain the topic, e.g. https://ntfy.sh/alerts":        "URL должен содержать топик, например https://ntfy.sh/alerts",
+	"Expected URL of the server and application token":                   "Ожидается URL сервера и токен приложения",
+	"Email is not configured on this bot":                                "Почта не настроена в этом боте",
+	"Expected comma-separated addresses":                                 "Ожидаются адреса через запятую",
+	"Only the bot's superusers can add email channels":                   "Добавлять почтовые каналы могут только суперпользователи бота",
+	"%v is not in %v, only the bot's superusers can add other addresses": "%v не входит в домены %v, другие адреса могут добавлять только суперпользователи бота",
+	"Invalid address %v":                                                 "Неверный адрес %v",
+	"Invalid URL, it must start with http:// or https://":                "Неверный URL, он должен начинаться с http:// или https://",
+	"Acknowledgement of the incident of <b>%v</b> was withdrawn":         "Подтверждение инцидента <b>%v</b> отозвано",
+	"Incident of <b>%v</b> was acknowledged by %v":                       "Инцидент <b>%v</b> подтвердил(а) %v",
+	"Incident of <b>%v</b> was acknowledged":                             "Инцидент <b>%v</b> подтверждён",
+
+	// Hooks.
+	"Usage: /hooks ID":                      "Использование: /hooks ID",
+	"No hooks were run for this target yet": "Хуки для этой цели ещё не запускались",
+	"Hook <code>%v</code> for <b>%v</b>":    "Хук <code>%v</code> для <b>%v</b>",
+	"%v %v succeeded in %v":                 "%v %v выполнен за %v",
+	"%v %v failed in %v: %v":                "%v %v завершился с ошибкой за %v: %v",
+	" (incident %v)":                        " (инцидент %v)",
+
+	// Import and export.
+	"%v targets": "Целей: %v",
+	"Send a JSON, CSV or YAML file with the targets, e.g. the one made by /export. Send /cancel if you've changed your mind.": "Отправьте файл JSON, CSV или YAML с целями, например созданный командой /export. Отправьте /cancel, если передумали.",
+	"Please send the targets as a file":                                         "Отправьте цели файлом",
+	"Could not download the file, please try again":                             "Не удалось скачать файл, попробуйте ещё раз",
+	"%v. Please send a fixed file":                                              "%v. Отправьте исправленный файл",
+	"Could not translate:":                                                      "Не удалось перенести:",
+	"The file contains no targets.":                                             "В файле нет целей.",
+	"Please send another file":                                                  "Отправьте другой файл",
+	"The file has errors:":                                                      "В файле есть ошибки:",
+	"The file has errors:\n%v":                                                  "В файле есть ошибки:\n%v",
+	"Please send a fixed file":                                                  "Отправьте исправленный файл",
+	"Send <b>yes</b> to apply the changes.":                                     "Отправьте <b>да</b>, чтобы применить изменения.",
+	"All the targets are up to date, nothing to import":                         "Все цели актуальны, импортировать нечего",
+	"Import has been canceled":                                                  "Импорт отменён",
+	"Imported: %v added, %v changed":                                            "Импортировано: добавлено %v, изменено %v",
+	"<b>%v</b> to add, <b>%v</b> to change, <b>%v</b> unchanged.":               "Добавить: <b>%v</b>, изменить: <b>%v</b>, без изменений: <b>%v</b>.",
+	"Unknown format %q, use json, csv or yaml":                                  "Неизвестный формат %q, используйте json, csv или yaml",
+	"Could not parse %v: %v":                                                    "Не удалось разобрать %v: %v",
+	"file is too large":                                                         "файл слишком большой",
+	"could not download the file: %v":                                           "не удалось скачать файл: %v",
+	"row %v: invalid url %q: %v":                                                "строка %v: неверный URL %q: %v",
+	"row %v: %v":                                                                "строка %v: %v",
+	"row %v: invalid template: %v":                                              "строка %v: неверный шаблон: %v",
+	"row %v: duplicates row %v":                                                 "строка %v: повторяет строку %v",
+	"row %v: invalid id %q":                                                     "строка %v: неверный id %q",
+	"row %v: invalid %v value %q":                                               "строка %v: неверное значение %[3]q в столбце %[2]v",
+	"CSV header must contain url column":                                        "Заголовок CSV должен содержать столбец url",
+	"%v: %v checks are not supported":                                           "%v: проверки %v не поддерживаются",
+	"%v: keyword is not checked, imported as a plain HTTP check":                "%v: ключевое слово не проверяется, импортировано как обычная HTTP-проверка",
+	"check intervals: all targets are polled with the bot's global interval":    "интервалы проверок: все цели опрашиваются с общим интервалом бота",
+	"job %v: not a blackbox exporter job":                                       "задание %v: не задание blackbox exporter",
+	"job %v: %v uses module %v, which is not an HTTP check":                     "задание %v: %v использует модуль %v, который не является HTTP-проверкой",
+	"job %v: settings of module %v are unknown, imported as a plain HTTP check": "задание %v: настройки модуля %v неизвестны, импортировано как обычная HTTP-проверка",
+	"job %v: targets from %v can't be imported, only static_configs":            "задание %v: цели из %v нельзя импортировать, только static_configs",
+
+	// Templates.
+	"/template - show the templates of the chat's notifications":    "/template - показать шаблоны уведомлений чата",
+	"/template chat TEXT - set the template of the chat":            "/template chat ТЕКСТ - задать шаблон чата",
+	"/template ID TEXT - set the template of a target":              "/template ID ТЕКСТ - задать шаблон цели",
+	"/template chat off, /template ID off - go back to the default": "/template chat off, /template ID off - вернуть шаблон по умолчанию",
+	"/preview [ID] - render the template with sample data":          "/preview [ID] - показать шаблон на примере",
+	"Templates use Go template syntax with Telegram HTML, e.g.":     "Шаблоны используют синтаксис шаблонов Go и HTML Telegram, например",
+	"Fields: .Target (.ID, .Title, .URL, .Tags, .Critical), .Status and .PrevStatus (.Type, .OK, .Error, .ResponseTime, .HTTPStatusCode, .HTTPStatusText), .Emoji, .Time, .IncidentStart, .Duration, .Recovery, .Verbosity, .HideDetails, .Language. Functions: repeat, tags, duration. Methods in the chat's language: .T for translation, .FormatTime, .FormatDuration.": "Поля: .Target (.ID, .Title, .URL, .Tags, .Critical), .Status и .PrevStatus (.Type, .OK, .Error, .ResponseTime, .HTTPStatusCode, .HTTPStatusText), .Emoji, .Time, .IncidentStart, .Duration, .Recovery, .Verbosity, .HideDetails, .Language. Функции: repeat, tags, duration. Методы на языке чата: .T для перевода, .FormatTime, .FormatDuration.",
+	"Invalid template: %v\n\n%v":                                  "Неверный шаблон: %v\n\n%v",
+	"Invalid notification template: %v":                           "Неверный шаблон уведомлений: %v",
+	"Template of the chat was changed, see it with /preview":      "Шаблон чата изменён, посмотрите его командой /preview",
+	"Template of the target was changed, see it with /preview %v": "Шаблон цели изменён, посмотрите его командой /preview %v",
+	"<b>Chat:</b>":                             "<b>Чат:</b>",
+	"<b>Chat:</b> the bot's default":           "<b>Чат:</b> шаблон бота по умолчанию",
+	"<b>Chat:</b> the built-in default":        "<b>Чат:</b> встроенный шаблон",
+	"Usage: /preview [ID]":                     "Использование: /preview [ID]",
+	"Error while rendering the template: %v":   "Ошибка при выводе шаблона: %v",
+	"The message is empty":                     "Сообщение пустое",
+	"The message is longer than %v characters": "Сообщение длиннее %v символов",
+	"Tag <%v> is not supported by Telegram":    "Тег <%v> не поддерживается Telegram",
+	"Unexpected </%v>":                         "Неожиданный </%v>",
+	"repeat count must be between 0 and %v":    "число повторов должно быть от 0 до %v",
+	"Tag <%v> is not closed":                   "Тег <%v> не закрыт",
+
+	// Routing, see sendRoute.
+	"Usage: /route ID [%v] [mon..sun] [HH:MM]":          "Использование: /route ID [%v] [mon..sun] [ЧЧ:ММ]",
+	"<b>%v</b> (%v) is %v on %v:":                       "<b>%v</b> (%v) в статусе %v, %v:",
+	"No rule matched":                                   "Ни одно правило не подошло",
+	"No rule matched, the update is delivered as usual": "Ни одно правило не подошло, уведомление доставляется как обычно",
+	"Matched rules: %v":                                 "Подошедшие правила: %v",
+	"The update is dropped":                             "Уведомление никуда не доставляется",
+	"%v (this chat)":                                    "%v (этот чат)",
+	"Chats: %v":                                         "Чаты: %v",
+	"Other chats: %v":                                   "Другие чаты: %v",
+	"Channel %v: %v of chat %v":                         "Канал %v: %v чата %v",
+	"Channels of other chats: %v":                       "Каналы других чатов: %v",
+	"All global notifiers: %v":                          "Все глобальные получатели: %v",
+	"Notifiers: %v":                                     "Получатели: %v",
+	"Sunday":                                            "воскресенье",
+	"Monday":                                            "понедельник",
+	"Tuesday":                                           "вторник",
+	"Wednesday":                                         "среда",
+	"Thursday":                                          "четверг",
+	"Friday":                                            "пятница",
+	"Saturday":                                          "суббота",
+}
@@ -0,0 +1,110 @@
+package telegrambot
+
+import (
+	"fmt"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Policies describing who is allowed to add, edit and delete targets of
+// a group chat.
+const (
+	// PolicyEveryone - any member of the chat.
+	PolicyEveryone = "everyone"
+	// PolicyAdmins - only the chat administrators.
+	PolicyAdmins = "admins"
+	// PolicyAllowlist - the chat administrators and explicitly allowed users.
+	PolicyAllowlist = "allowlist"
+)
+
+func isValidPolicy(policy string) bool {
+	switch policy {
+	case PolicyEveryone, PolicyAdmins, PolicyAllowlist:
+		return true
+	}
+	return false
+}
+
+func (b *Bot) isSuperuser(userID int) bool {
+	for _, id := range b.Superusers {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (b *Bot) isChatAdmin(chatID int64, userID int) (bool, error) {
+	member, err := b.TgBot.GetChatMember(tgbotapi.ChatConfigWithUser{
+		ChatID: chatID,
+		UserID: userID,
+	})
+	if err != nil {
+		return false, err
+	}
+	return member.IsCreator() || member.IsAdministrator(), nil
+}
+
+// canChangeSettings reports whether the author of the message may change
+// the chat's settings. Only chat administrators and superusers can do it.
+func (b *Bot) canChangeSettings(msg *tgbotapi.Message) (bool, error) {
+	if msg.From == nil {
+		return false, nil
+	}
+	if msg.Chat.IsPrivate() || b.isSuperuser(msg.From.ID) {
+		return true, nil
+	}
+	return b.isChatAdmin(msg.Chat.ID, msg.From.ID)
+}
+
+// canManageTargets reports whether the author of the message may add, edit
+// and delete targets of the chat according to the chat's policy.
+func (b *Bot) canManageTargets(msg *tgbotapi.Message) (bool, error) {
+	if msg.From == nil {
+		return false, nil
+	}
+	if msg.Chat.IsPrivate() || b.isSuperuser(msg.From.ID) {
+		return true, nil
+	}
+
+	settings, err := b.getChatSettings(msg.Chat.ID)
+	if err != nil {
+		return false, err
+	}
+	if settings.ManagePolicy == PolicyEveryone {
+		return true, nil
+	}
+
+	if settings.ManagePolicy == PolicyAllowlist {
+		allowed, err := b.DB.IsUserAllowed(msg.Chat.ID, msg.From.ID)
+		if err != nil {
+			return false, err
+		}
+		if allowed {
+			return true, nil
+		}
+	}
+
+	return b.isChatAdmin(msg.Chat.ID, msg.From.ID)
+}
+
+// checkPermission runs the check and reports the denial or the error to the
+// chat. It returns true only if the action is allowed.
+func (b *Bot) checkPermission(msg *tgbotapi.Message, check func(*tgbotapi.Message) (bool, error)) bool {
+	allowed, err := check(msg)
+	if err != nil {
+		fmt.Println(err)
+		b.SendMessage(
+			msg.Chat.ID,
+			b.tr(
+				msg.Chat.ID,
+				"Error while checking your permissions, please contact the administrator: %v",
+				b.AdminNickname))
+		return false
+	}
+	if !allowed {
+		b.SendMessage(msg.Chat.ID, b.tr(msg.Chat.ID, "Sorry, you are not allowed to do this in this chat"))
+		return false
+	}
+	return true
+}
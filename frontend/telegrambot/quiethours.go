@@ -0,0 +1,233 @@
+package telegrambot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// What happens to notifications of non-critical targets during quiet hours.
+const (
+	// QuietModeSilent - notifications are delivered without sound.
+	QuietModeSilent = "silent"
+	// QuietModeQueue - notifications are queued and delivered as a digest
+	// when quiet hours end.
+	QuietModeQueue = "queue"
+)
+
+// quietHours is a daily time interval, e.g. 23:00-07:00. Start and end are
+// offsets from midnight. The interval may wrap around midnight.
+type quietHours struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+func parseClock(s string) (time.Duration, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return 0, errorf("Invalid time %q, use HH:MM", s)
+	}
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, errorf("Invalid time %q, use HH:MM", s)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, errorf("Invalid time %q, use HH:MM", s)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+// parseQuietHours parses an interval of format HH:MM-HH:MM.
+func parseQuietHours(s string) (quietHours, error) {
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return quietHours{}, errorf("Invalid interval %q, use HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return quietHours{}, err
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return quietHours{}, err
+	}
+	return quietHours{start, end}, nil
+}
+
+// Contains reports whether the time of day of t falls into the interval.
+func (qh quietHours) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if qh.Start <= qh.End {
+		return offset >= qh.Start && offset < qh.End
+	}
+	return offset >= qh.Start || offset < qh.End
+}
+
+// isQuietTime reports whether the chat has quiet hours at the given time.
+func (s *ChatSettings) isQuietTime(t time.Time) bool {
+	if s.QuietHours == "" {
+		return false
+	}
+	qh, err := parseQuietHours(s.QuietHours)
+	if err != nil {
+		return false
+	}
+	return qh.Contains(t.In(s.Location()))
+}
+
+func (b *Bot) queueNotification(chatID int64, rec *Record, upd monitor.StatusUpdate) error {
+	errMsg := ""
+	if upd.Status.Err != nil {
+		errMsg = upd.Status.Err.Error()
+	}
+	return b.DB.QueueNotification(QueuedNotification{
+		ChatID:     chatID,
+		TargetID:   rec.ID,
+		Title:      rec.Title,
+		URL:        rec.URL,
+		StatusType: upd.Status.Type.String(),
+		Err:        errMsg,
+	})
+}
+
+func (b *Bot) formatDigest(queued []QueuedNotification, settings ChatSettings) string {
+	lang := b.languageOf(settings)
+	var lines []string
+	lines = append(lines, translate(lang, "<b>Status changes during quiet hours:</b>")+"\n")
+
+	lastStatuses := map[uint]QueuedNotification{}
+	var order []uint
+	for _, qn := range queued {
+		stype, _ := monitor.ScanStatusType(qn.StatusType)
+		line := fmt.Sprintf(
+			"%v %v <b>%v</b>: %v",
+			qn.CreatedAt.In(settings.Location()).Format("15:04"),
+			statusEmoji(stype), replaceHTML(qn.Title), translate(lang, qn.StatusType))
+		if stype != monitor.StatusOK && !settings.HideDetails {
+			line += fmt.Sprintf(" (%v)", replaceHTML(qn.Err))
+		}
+		lines = append(lines, line)
+
+		if _, ok := lastStatuses[qn.TargetID]; !ok {
+			order = append(order, qn.TargetID)
+		}
+		lastStatuses[qn.TargetID] = qn
+	}
+
+	var stillDown []string
+	for _, id := range order {
+		qn := lastStatuses[id]
+		if qn.StatusType != monitor.StatusOK.String() {
+			stillDown = append(stillDown, fmt.Sprintf(
+				"<a href=\"%v\">%v</a>", replaceHTML(qn.URL), replaceHTML(qn.Title)))
+		}
+	}
+	if len(stillDown) > 0 {
+		lines = append(lines, "\n"+translate(lang, "<b>Still down:</b> %v", strings.Join(stillDown, ", ")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// deliverDigests sends the queued notifications of every chat, which quiet
+// hours have ended, as a single digest message.
+func (b *Bot) deliverDigests() {
+	for range time.Tick(time.Minute) {
+		chatIDs, err := b.DB.GetQueuedChats()
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		for _, chatID := range chatIDs {
+			settings, err := b.getChatSettings(chatID)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if settings.isQuietTime(time.Now()) {
+				continue
+			}
+
+			queued, err := b.DB.GetQueuedNotifications(chatID)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			if len(queued) == 0 {
+				continue
+			}
+			b.SendMessage(chatID, b.formatDigest(queued, settings))
+			if err := b.DB.DeleteQueuedNotifications(chatID, queued[len(queued)-1].ID); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+type markCritical struct {
+	bot *Bot
+}
+
+func (t *markCritical) Kind() string { return "critical" }
+
+func (t *markCritical) apply(message *tgbotapi.Message, text string) {
+	id, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "Invalid ID"))
+		return
+	}
+	target, err := t.bot.DB.GetTarget(id)
+	if err != nil || target.ChatID != message.Chat.ID {
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "No target with such ID found"))
+		return
+	}
+
+	before := *target
+	target.Critical = !target.Critical
+	err = t.bot.DB.UpdateTarget(*target)
+	if err != nil {
+		t.bot.SendMessage(
+			message.Chat.ID,
+			t.bot.tr(
+				message.Chat.ID,
+				"Error while editing the target, please contact the administrator: %v",
+				t.bot.AdminNickname))
+		return
+	}
+	t.bot.audit(message, ActionEdit, &before, target)
+
+	if target.Critical {
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "Target is now critical, its notifications ignore quiet hours"))
+	} else {
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "Target is no longer critical"))
+	}
+}
+
+func (t *markCritical) ContinueDialog(stepNumber int, update tgbotapi.Update, bot *tgbotapi.BotAPI) (int, bool) {
+	if stepNumber == 1 {
+		if args := update.Message.CommandArguments(); args != "" {
+			t.apply(update.Message, args)
+			return 0, false
+		}
+		ok := t.bot.sendTargetChoice(
+			update.Message,
+			t.bot.tr(
+				update.Message.Chat.ID,
+				"Enter the <b>ID</b> of a target to toggle its critical flag. Send /cancel if you've changed your mind."))
+		if !ok {
+			return 0, false
+		}
+		return 2, true
+	}
+	if stepNumber == 2 {
+		t.apply(update.Message, update.Message.Text)
+		return 0, false
+	}
+	return 0, false
+}
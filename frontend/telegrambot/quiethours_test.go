@@ -0,0 +1,64 @@
+package telegrambot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseQuietHours(t *testing.T) {
+	cases := []struct {
+		s          string
+		start, end time.Duration
+	}{
+		{"23:00-07:30", 23 * time.Hour, 7*time.Hour + 30*time.Minute},
+		{"09:00-18:00", 9 * time.Hour, 18 * time.Hour},
+		{" 0:05 - 1:00 ", 5 * time.Minute, time.Hour},
+	}
+	for _, c := range cases {
+		qh, err := parseQuietHours(c.s)
+		if err != nil || qh.Start != c.start || qh.End != c.end {
+			t.Errorf("parseQuietHours(%q) = %+v, %v, want %v-%v", c.s, qh, err, c.start, c.end)
+		}
+	}
+
+	for _, s := range []string{"", "23:00", "23:00-07:00-08:00", "24:00-07:00", "23:60-07:00", "23-07", "ab:cd-07:00"} {
+		if _, err := parseQuietHours(s); err == nil {
+			t.Errorf("parseQuietHours(%q) succeeded", s)
+		}
+	}
+}
+
+func TestQuietHoursContains(t *testing.T) {
+	at := func(clock string) time.Time {
+		offset, err := parseClock(clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC).Add(offset)
+	}
+	overnight := quietHours{23 * time.Hour, 7 * time.Hour}
+	daytime := quietHours{9 * time.Hour, 18 * time.Hour}
+
+	cases := []struct {
+		qh       quietHours
+		clock    string
+		contains bool
+	}{
+		{overnight, "23:00", true},
+		{overnight, "02:00", true},
+		{overnight, "06:59", true},
+		{overnight, "07:00", false},
+		{overnight, "22:59", false},
+		{overnight, "12:00", false},
+		{daytime, "09:00", true},
+		{daytime, "17:59", true},
+		{daytime, "18:00", false},
+		{daytime, "08:59", false},
+		{daytime, "23:00", false},
+	}
+	for _, c := range cases {
+		if got := c.qh.Contains(at(c.clock)); got != c.contains {
+			t.Errorf("%+v contains %v: %v, want %v", c.qh, c.clock, got, c.contains)
+		}
+	}
+}
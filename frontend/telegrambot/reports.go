@@ -0,0 +1,314 @@
+package telegrambot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// targetStats is a summary of a target's poll history for some period.
+type targetStats struct {
+	Polls      int
+	OKPolls    int
+	Incidents  int
+	Downtime   time.Duration
+	AvgLatency time.Duration
+}
+
+// Uptime returns the share of successful polls in percents.
+func (ts targetStats) Uptime() float64 {
+	if ts.Polls == 0 {
+		return 0
+	}
+	return float64(ts.OKPolls) / float64(ts.Polls) * 100
+}
+
+// computeStats summarizes the poll records, which must be ordered by time.
+// A failed poll is considered to last until the next poll, but no longer
+// than maxGap, so that periods when the bot was not running are not counted
+// as downtime.
+func computeStats(records []PollRecord, until time.Time, maxGap time.Duration) targetStats {
+	var stats targetStats
+	var latencySum time.Duration
+	wasDown := false
+
+	for i, rec := range records {
+		stats.Polls++
+
+		if rec.Type == monitor.StatusOK {
+			stats.OKPolls++
+			latencySum += rec.ResponseTime
+			wasDown = false
+			continue
+		}
+
+		if !wasDown {
+			stats.Incidents++
+		}
+		wasDown = true
+
+		next := until
+		if i+1 < len(records) {
+			next = records[i+1].Time
+		}
+		gap := next.Sub(rec.Time)
+		if gap > maxGap {
+			gap = maxGap
+		}
+		stats.Downtime += gap
+	}
+
+	if stats.OKPolls > 0 {
+		stats.AvgLatency = latencySum / time.Duration(stats.OKPolls)
+	}
+	return stats
+}
+
+// parsePeriod parses periods like 24h, 7d or 2w.
+func parsePeriod(s string) (time.Duration, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	if len(s) < 2 {
+		return 0, errorf("Invalid period %q, use e.g. 24h, 7d or 2w", s)
+	}
+
+	units := map[byte]time.Duration{
+		'h': time.Hour,
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+	unit, ok := units[s[len(s)-1]]
+	if !ok {
+		return 0, errorf("Invalid period %q, use e.g. 24h, 7d or 2w", s)
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n <= 0 {
+		return 0, errorf("Invalid period %q, use e.g. 24h, 7d or 2w", s)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+func formatPeriod(lang string, period time.Duration) string {
+	day := 24 * time.Hour
+	if period%day == 0 && period != day {
+		return formatCount(lang, int64(period/day), "day")
+	}
+	return formatCount(lang, int64(period/time.Hour), "hour")
+}
+
+// formatDuration formats the duration rounded to minutes, e.g. "1h 5m".
+func formatDuration(lang string, d time.Duration) string {
+	if d < time.Minute {
+		return translate(lang, "%vs", int64(d/time.Second))
+	}
+	d = d / time.Minute * time.Minute
+	hours := int64(d / time.Hour)
+	minutes := int64(d % time.Hour / time.Minute)
+	if hours == 0 {
+		return translate(lang, "%vm", minutes)
+	}
+	if minutes == 0 {
+		return translate(lang, "%vh", hours)
+	}
+	return translate(lang, "%vh %vm", hours, minutes)
+}
+
+// buildReport formats uptime statistics of the chat's targets for the period
+// ending now.
+func (b *Bot) buildReport(chatID int64, period time.Duration) (string, error) {
+	lang := b.chatLanguage(chatID)
+	targs, err := b.DB.GetVisibleTargets(chatID)
+	if err != nil {
+		return "", err
+	}
+	if len(targs) == 0 {
+		return translate(lang, "No targets! Use /add to add one."), nil
+	}
+
+	now := time.Now()
+	since := now.Add(-period)
+	maxGap := 2 * b.Monitor.Scheduler.Interval
+
+	lines := []string{translate(lang, "<b>Uptime report for the last %v</b>", formatPeriod(lang, period)) + "\n"}
+	for _, target := range targs {
+		records, err := b.DB.GetPollHistory(target.ID, since)
+		if err != nil {
+			return "", err
+		}
+
+		header := fmt.Sprintf("<b>%v</b>", replaceHTML(target.Title))
+		if len(records) == 0 {
+			lines = append(lines, translate(lang, "%v: no data", header))
+			continue
+		}
+
+		stats := computeStats(records, now, maxGap)
+		lines = append(lines, translate(
+			lang,
+			"%v: %.2f%% uptime, %v incidents, %v down, %v ms avg",
+			header,
+			stats.Uptime(),
+			stats.Incidents,
+			formatDuration(lang, stats.Downtime),
+			int64(stats.AvgLatency/time.Millisecond)))
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// reportSchedule describes when a scheduled report is due.
+type reportSchedule struct {
+	// Report period, one day or one week.
+	Period time.Duration
+	// For weekly reports the day of the week.
+	Weekday time.Weekday
+	// Time of day as offset from midnight.
+	Clock time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseReportSchedule parses schedules like "daily 09:00" or
+// "weekly mon 09:00".
+func parseReportSchedule(s string) (reportSchedule, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	invalid := errorf("Invalid schedule %q, use e.g. daily 09:00 or weekly mon 09:00", s)
+
+	switch {
+	case len(fields) == 2 && fields[0] == "daily":
+		clock, err := parseClock(fields[1])
+		if err != nil {
+			return reportSchedule{}, err
+		}
+		return reportSchedule{Period: 24 * time.Hour, Clock: clock}, nil
+	case len(fields) == 3 && fields[0] == "weekly":
+		weekday, ok := weekdays[fields[1]]
+		if !ok {
+			return reportSchedule{}, invalid
+		}
+		clock, err := parseClock(fields[2])
+		if err != nil {
+			return reportSchedule{}, err
+		}
+		return reportSchedule{Period: 7 * 24 * time.Hour, Weekday: weekday, Clock: clock}, nil
+	}
+	return reportSchedule{}, invalid
+}
+
+// atClock returns the time of day of t's date given as offset from midnight.
+// Unlike adding the offset to midnight, it stays right on days when clocks
+// are changed.
+func atClock(t time.Time, clock time.Duration) time.Time {
+	return time.Date(
+		t.Year(), t.Month(), t.Day(),
+		int(clock/time.Hour), int(clock%time.Hour/time.Minute), 0, 0, t.Location())
+}
+
+// LastDue returns the latest time, not after now, when the report was due.
+func (rs reportSchedule) LastDue(now time.Time) time.Time {
+	due := atClock(now, rs.Clock)
+	if rs.Period > 24*time.Hour {
+		shift := (int(due.Weekday()) - int(rs.Weekday) + 7) % 7
+		due = due.AddDate(0, 0, -shift)
+	}
+	for due.After(now) {
+		if rs.Period > 24*time.Hour {
+			due = due.AddDate(0, 0, -7)
+		} else {
+			due = due.AddDate(0, 0, -1)
+		}
+	}
+	return due
+}
+
+// deliverReports sends scheduled reports to the chats when they are due.
+func (b *Bot) deliverReports() {
+	for range time.Tick(time.Minute) {
+		chatSettings, err := b.DB.GetScheduledReportSettings()
+		if err != nil {
+			fmt.Println(err)
+			continue
+		}
+
+		for _, settings := range chatSettings {
+			schedule, err := parseReportSchedule(settings.ReportSchedule)
+			if err != nil {
+				continue
+			}
+			due := schedule.LastDue(time.Now().In(settings.Location()))
+			if !settings.LastReportAt.Before(due) {
+				continue
+			}
+
+			report, err := b.buildReport(settings.ChatID, schedule.Period)
+			if err != nil {
+				fmt.Println(err)
+				continue
+			}
+			b.SendMessage(settings.ChatID, report)
+
+			if err := b.DB.SetLastReportAt(settings.ChatID, time.Now()); err != nil {
+				fmt.Println(err)
+			}
+		}
+	}
+}
+
+func (b *Bot) purgePollHistory() {
+	for range time.Tick(time.Hour) {
+		err := b.DB.PurgePollHistory(time.Now().Add(-b.HistoryRetention))
+		if err != nil {
+			fmt.Println(err)
+		}
+		err = b.DB.PurgeDeliveries(time.Now().Add(-b.HistoryRetention))
+		if err != nil {
+			fmt.Println(err)
+		}
+		err = b.DB.PurgeHookRuns(time.Now().Add(-b.HistoryRetention))
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func (b *Bot) sendReport(chatID int64, args string) {
+	lang := b.chatLanguage(chatID)
+	period := 7 * 24 * time.Hour
+	if args != "" {
+		var err error
+		period, err = parsePeriod(args)
+		if err != nil {
+			b.SendMessage(chatID, replaceHTML(translateError(lang, err)))
+			return
+		}
+	}
+	if period > b.HistoryRetention {
+		b.SendMessage(chatID, translate(
+			lang,
+			"History is only kept for %v", formatPeriod(lang, b.HistoryRetention)))
+		return
+	}
+
+	report, err := b.buildReport(chatID, period)
+	if err != nil {
+		fmt.Println(err)
+		b.SendMessage(
+			chatID,
+			translate(
+				lang,
+				"Error while building the report, please contact the administrator: %v",
+				b.AdminNickname))
+		return
+	}
+	b.SendMessage(chatID, report)
+}
@@ -0,0 +1,122 @@
+package telegrambot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+func TestComputeStats(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	poll := func(minute int, st monitor.StatusType, latency time.Duration) PollRecord {
+		return PollRecord{Time: start.Add(time.Duration(minute) * time.Minute), Type: st, ResponseTime: latency}
+	}
+	maxGap := 2 * time.Minute
+
+	cases := []struct {
+		name    string
+		records []PollRecord
+		until   time.Time
+		want    targetStats
+	}{
+		{"empty", nil, start, targetStats{}},
+		{
+			"all ok",
+			[]PollRecord{poll(0, monitor.StatusOK, 100*time.Millisecond), poll(1, monitor.StatusOK, 300*time.Millisecond)},
+			start.Add(2 * time.Minute),
+			targetStats{Polls: 2, OKPolls: 2, AvgLatency: 200 * time.Millisecond},
+		},
+		{
+			"two incidents",
+			[]PollRecord{
+				poll(0, monitor.StatusOK, 100*time.Millisecond),
+				poll(1, monitor.StatusTimeout, 0),
+				poll(2, monitor.StatusHTTPError, 0),
+				poll(3, monitor.StatusOK, 100*time.Millisecond),
+				poll(4, monitor.StatusTimeout, 0),
+			},
+			start.Add(5 * time.Minute),
+			targetStats{Polls: 5, OKPolls: 2, Incidents: 2, Downtime: 3 * time.Minute, AvgLatency: 100 * time.Millisecond},
+		},
+		{
+			// The bot was not running between the polls.
+			"gap is capped",
+			[]PollRecord{poll(0, monitor.StatusTimeout, 0), poll(60, monitor.StatusTimeout, 0)},
+			start.Add(120 * time.Minute),
+			targetStats{Polls: 2, Incidents: 1, Downtime: 2 * maxGap},
+		},
+	}
+	for _, c := range cases {
+		if got := computeStats(c.records, c.until, maxGap); got != c.want {
+			t.Errorf("%v: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+
+	stats := targetStats{Polls: 4, OKPolls: 3}
+	if stats.Uptime() != 75 {
+		t.Errorf("Uptime() = %v, want 75", stats.Uptime())
+	}
+	if (targetStats{}).Uptime() != 0 {
+		t.Errorf("Uptime() of no polls = %v, want 0", (targetStats{}).Uptime())
+	}
+}
+
+func TestReportScheduleLastDue(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	cases := []struct {
+		schedule string
+		now, due time.Time
+	}{
+		{"daily 09:00",
+			time.Date(2020, 1, 8, 10, 0, 0, 0, time.UTC),
+			time.Date(2020, 1, 8, 9, 0, 0, 0, time.UTC)},
+		{"daily 09:00",
+			time.Date(2020, 1, 8, 8, 59, 0, 0, time.UTC),
+			time.Date(2020, 1, 7, 9, 0, 0, 0, time.UTC)},
+		{"daily 09:00",
+			time.Date(2020, 1, 8, 9, 0, 0, 0, time.UTC),
+			time.Date(2020, 1, 8, 9, 0, 0, 0, time.UTC)},
+		// 2020-01-08 is Wednesday.
+		{"weekly mon 09:00",
+			time.Date(2020, 1, 8, 10, 0, 0, 0, time.UTC),
+			time.Date(2020, 1, 6, 9, 0, 0, 0, time.UTC)},
+		{"weekly wed 09:00",
+			time.Date(2020, 1, 8, 8, 0, 0, 0, time.UTC),
+			time.Date(2020, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{"weekly sun 23:30",
+			time.Date(2020, 1, 8, 8, 0, 0, 0, time.UTC),
+			time.Date(2020, 1, 5, 23, 30, 0, 0, time.UTC)},
+		// Clocks go forward on 2020-03-08 and back on 2020-11-01 in New York.
+		{"daily 09:00",
+			time.Date(2020, 3, 8, 12, 0, 0, 0, newYork),
+			time.Date(2020, 3, 8, 9, 0, 0, 0, newYork)},
+		{"daily 09:00",
+			time.Date(2020, 11, 1, 12, 0, 0, 0, newYork),
+			time.Date(2020, 11, 1, 9, 0, 0, 0, newYork)},
+		{"weekly sun 09:00",
+			time.Date(2020, 3, 10, 12, 0, 0, 0, newYork),
+			time.Date(2020, 3, 8, 9, 0, 0, 0, newYork)},
+	}
+	for _, c := range cases {
+		rs, err := parseReportSchedule(c.schedule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if due := rs.LastDue(c.now); !due.Equal(c.due) {
+			t.Errorf("%q at %v: due %v, want %v", c.schedule, c.now, due, c.due)
+		}
+	}
+}
+
+func TestParseReportSchedule(t *testing.T) {
+	for _, s := range []string{"", "daily", "daily 25:00", "weekly 09:00", "weekly xyz 09:00", "monthly 09:00"} {
+		if _, err := parseReportSchedule(s); err == nil {
+			t.Errorf("parseReportSchedule(%q) succeeded", s)
+		}
+	}
+}
@@ -0,0 +1,376 @@
+package telegrambot
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// RoutingRule decides where status updates go. Conditions, which are empty,
+// hold for every update. A rule without destinations drops the updates it
+// matches.
+type RoutingRule struct {
+	// Shown by /route, defaults to the rule's number.
+	Name string
+	// The target has any of the tags.
+	Tags []string
+	// The status is one of: down, up, error, timeout, url, dns, http.
+	Status []string
+	// The target is critical or normal.
+	Severity []string
+	// Daily interval like 09:00-18:00 and days of the week like mon.
+	Hours string
+	Days  []string
+	// Time zone of Hours and Days. Defaults to UTC.
+	Timezone string
+
+	// Whether the chats owning and subscribed to the target receive the update.
+	TargetChats bool
+	// Other chats receiving the update.
+	Chats []int64
+	// Names of global notifiers receiving the update, see NamedNotifiers.
+	Notifiers []string
+	// Whether the following rules are evaluated after this one matches.
+	Continue bool
+}
+
+var routingStatuses = map[string]func(monitor.StatusType) bool{
+	"down":    func(st monitor.StatusType) bool { return st != monitor.StatusOK },
+	"up":      func(st monitor.StatusType) bool { return st == monitor.StatusOK },
+	"error":   func(st monitor.StatusType) bool { return st == monitor.StatusGenericError },
+	"timeout": func(st monitor.StatusType) bool { return st == monitor.StatusTimeout },
+	"url":     func(st monitor.StatusType) bool { return st == monitor.StatusURLParsingError },
+	"dns":     func(st monitor.StatusType) bool { return st == monitor.StatusDNSLookupError },
+	"http":    func(st monitor.StatusType) bool { return st == monitor.StatusHTTPError },
+}
+
+// routingRule is a validated RoutingRule.
+type routingRule struct {
+	RoutingRule
+	hours    *quietHours
+	days     map[time.Weekday]bool
+	location *time.Location
+}
+
+// SetRoutingRules validates and sets the rules, which are evaluated in order
+// for every status update. Updates matching no rule are delivered as if there
+// were no rules: to the target's chats, their channels and all Notifiers,
+// unless DropUnrouted is set.
+func (b *Bot) SetRoutingRules(rules []RoutingRule) error {
+	var compiled []routingRule
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = strconv.Itoa(i + 1)
+		}
+		rr := routingRule{RoutingRule: rule, location: time.UTC}
+
+		for _, status := range rule.Status {
+			if routingStatuses[status] == nil {
+				return fmt.Errorf("rule %v: unknown status %q", rule.Name, status)
+			}
+		}
+		for _, severity := range rule.Severity {
+			if severity != "critical" && severity != "normal" {
+				return fmt.Errorf("rule %v: unknown severity %q, use critical or normal", rule.Name, severity)
+			}
+		}
+		if rule.Hours != "" {
+			hours, err := parseQuietHours(rule.Hours)
+			if err != nil {
+				return fmt.Errorf("rule %v: %v", rule.Name, err)
+			}
+			rr.hours = &hours
+		}
+		if len(rule.Days) > 0 {
+			rr.days = map[time.Weekday]bool{}
+			for _, day := range rule.Days {
+				weekday, ok := weekdays[strings.ToLower(day)]
+				if !ok {
+					return fmt.Errorf("rule %v: unknown day %q", rule.Name, day)
+				}
+				rr.days[weekday] = true
+			}
+		}
+		if rule.Timezone != "" {
+			loc, err := time.LoadLocation(rule.Timezone)
+			if err != nil {
+				return fmt.Errorf("rule %v: %v", rule.Name, err)
+			}
+			rr.location = loc
+		}
+		for _, name := range rule.Notifiers {
+			if b.NamedNotifiers[name] == nil {
+				return fmt.Errorf("rule %v: unknown notifier %q", rule.Name, name)
+			}
+		}
+
+		compiled = append(compiled, rr)
+	}
+	b.routingRules = compiled
+	return nil
+}
+
+// Matches reports whether the status update of the target at the given time
+// satisfies the rule's conditions.
+func (rr *routingRule) Matches(rec *Record, st monitor.StatusType, at time.Time) bool {
+	if len(rr.Tags) > 0 && !hasAnyTag(rec.TagList(), rr.Tags) {
+		return false
+	}
+	if len(rr.Status) > 0 {
+		matched := false
+		for _, status := range rr.Status {
+			matched = matched || routingStatuses[status](st)
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(rr.Severity) > 0 {
+		severity := "normal"
+		if rec.Critical {
+			severity = "critical"
+		}
+		matched := false
+		for _, s := range rr.Severity {
+			matched = matched || s == severity
+		}
+		if !matched {
+			return false
+		}
+	}
+	at = at.In(rr.location)
+	if rr.hours != nil && !rr.hours.Contains(at) {
+		return false
+	}
+	if rr.days != nil && !rr.days[at.Weekday()] {
+		return false
+	}
+	return true
+}
+
+func hasAnyTag(tags, wanted []string) bool {
+	for _, tag := range tags {
+		for _, w := range wanted {
+			if strings.EqualFold(tag, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// route is where a status update is delivered.
+type route struct {
+	// Names of the matched rules, empty if the update is delivered by default.
+	Rules     []string
+	Chats     []int64
+	Notifiers []string
+	// Whether all Notifiers receive the update.
+	AllNotifiers bool
+}
+
+// routeUpdate evaluates the routing rules for the status update of
+// the target.
+func (b *Bot) routeUpdate(rec *Record, st monitor.StatusType, at time.Time) route {
+	var r route
+	seenChats := map[int64]bool{}
+	addChats := func(chats []int64) {
+		for _, chatID := range chats {
+			if !seenChats[chatID] {
+				seenChats[chatID] = true
+				r.Chats = append(r.Chats, chatID)
+			}
+		}
+	}
+	seenNotifiers := map[string]bool{}
+
+	for i := range b.routingRules {
+		rule := &b.routingRules[i]
+		if !rule.Matches(rec, st, at) {
+			continue
+		}
+		r.Rules = append(r.Rules, rule.Name)
+		if rule.TargetChats {
+			addChats(b.targetChats(rec))
+		}
+		addChats(rule.Chats)
+		for _, name := range rule.Notifiers {
+			if !seenNotifiers[name] {
+				seenNotifiers[name] = true
+				r.Notifiers = append(r.Notifiers, name)
+			}
+		}
+		if !rule.Continue {
+			break
+		}
+	}
+
+	if len(r.Rules) == 0 && !b.DropUnrouted {
+		addChats(b.targetChats(rec))
+		r.AllNotifiers = true
+	}
+	return r
+}
+
+// notifiers returns the global notifiers receiving the update.
+func (r route) notifiers(b *Bot) []notify.Notifier {
+	if r.AllNotifiers {
+		return b.Notifiers
+	}
+	var notifiers []notify.Notifier
+	for _, name := range r.Notifiers {
+		notifiers = append(notifiers, b.NamedNotifiers[name])
+	}
+	return notifiers
+}
+
+// parseRouteTime parses the time of a dry run like "sat 10:00", "10:00" or
+// "sat". The day is taken from the current week, the time from now.
+func parseRouteTime(args []string, now time.Time) (time.Time, error) {
+	at := now
+	for _, arg := range args {
+		if weekday, ok := weekdays[strings.ToLower(arg)]; ok {
+			at = at.AddDate(0, 0, int(weekday)-int(at.Weekday()))
+			continue
+		}
+		clock, err := parseClock(arg)
+		if err != nil {
+			return time.Time{}, err
+		}
+		at = atClock(at, clock)
+	}
+	return at, nil
+}
+
+func routeUsage(lang string) string {
+	var statuses []string
+	for status := range routingStatuses {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	return translate(lang, "Usage: /route ID [%v] [mon..sun] [HH:MM]", strings.Join(statuses, "|"))
+}
+
+// sendRoute handles /route command, which shows where a status update of
+// the target would be delivered without delivering it.
+func (b *Bot) sendRoute(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		b.SendMessage(message.Chat.ID, routeUsage(lang))
+		return
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		b.SendMessage(message.Chat.ID, routeUsage(lang))
+		return
+	}
+	rec, err := b.DB.GetTarget(id)
+	if err != nil || !b.isVisibleTo(rec, message.Chat.ID) {
+		b.SendMessage(message.Chat.ID, translate(lang, "No target with such ID found"))
+		return
+	}
+
+	status, st := "down", monitor.StatusGenericError
+	args = args[1:]
+	if len(args) > 0 && routingStatuses[args[0]] != nil {
+		status = args[0]
+		for _, candidate := range []monitor.StatusType{
+			monitor.StatusOK,
+			monitor.StatusGenericError,
+			monitor.StatusTimeout,
+			monitor.StatusURLParsingError,
+			monitor.StatusDNSLookupError,
+			monitor.StatusHTTPError,
+		} {
+			if routingStatuses[status](candidate) {
+				st = candidate
+				break
+			}
+		}
+		args = args[1:]
+	}
+
+	settings, err := b.getChatSettings(message.Chat.ID)
+	if err != nil {
+		fmt.Println(err)
+	}
+	at, err := parseRouteTime(args, time.Now().In(settings.Location()))
+	if err != nil {
+		b.SendMessage(message.Chat.ID, replaceHTML(translateError(lang, err))+"\n"+routeUsage(lang))
+		return
+	}
+
+	r := b.routeUpdate(rec, st, at)
+	lines := []string{translate(
+		lang,
+		"<b>%v</b> (%v) is %v on %v:",
+		replaceHTML(rec.Title), rec.ID, status,
+		translate(lang, at.Weekday().String())+" "+formatTime(lang, at, "2006-01-02 15:04 MST"))}
+	if len(r.Rules) == 0 && b.DropUnrouted {
+		lines = append(lines, translate(lang, "No rule matched"))
+	} else if len(r.Rules) == 0 {
+		lines = append(lines, translate(lang, "No rule matched, the update is delivered as usual"))
+	} else {
+		lines = append(lines, translate(lang, "Matched rules: %v", replaceHTML(strings.Join(r.Rules, ", "))))
+	}
+
+	if len(r.Chats) == 0 && len(r.notifiers(b)) == 0 {
+		lines = append(lines, translate(lang, "The update is dropped"))
+		b.SendMessage(message.Chat.ID, strings.Join(lines, "\n"))
+		return
+	}
+	// Other chats and their channels are only disclosed to superusers.
+	full := message.From != nil && b.isSuperuser(message.From.ID)
+	var chats []string
+	otherChats := 0
+	for _, chatID := range r.Chats {
+		if chatID == message.Chat.ID {
+			chats = append(chats, translate(lang, "%v (this chat)", chatID))
+		} else if full {
+			chats = append(chats, strconv.FormatInt(chatID, 10))
+		} else {
+			otherChats++
+		}
+	}
+	if len(chats) > 0 {
+		lines = append(lines, translate(lang, "Chats: %v", strings.Join(chats, ", ")))
+	}
+	if otherChats > 0 {
+		lines = append(lines, translate(lang, "Other chats: %v", otherChats))
+	}
+
+	var channels []NotificationChannel
+	if len(r.Chats) > 0 {
+		channels, err = b.DB.GetTargetChannels(r.Chats, rec.ID)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+	otherChannels := 0
+	for _, ch := range channels {
+		if ch.ChatID == message.Chat.ID || full {
+			lines = append(lines, translate(lang, "Channel %v: %v of chat %v", ch.ID, ch.Kind, ch.ChatID))
+		} else {
+			otherChannels++
+		}
+	}
+	if otherChannels > 0 {
+		lines = append(lines, translate(lang, "Channels of other chats: %v", otherChannels))
+	}
+
+	if r.AllNotifiers {
+		if len(b.Notifiers) > 0 {
+			lines = append(lines, translate(lang, "All global notifiers: %v", len(b.Notifiers)))
+		}
+	} else if len(r.Notifiers) > 0 {
+		lines = append(lines, translate(lang, "Notifiers: %v", replaceHTML(strings.Join(r.Notifiers, ", "))))
+	}
+	b.SendMessage(message.Chat.ID, strings.Join(lines, "\n"))
+}
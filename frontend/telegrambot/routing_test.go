@@ -0,0 +1,145 @@
+package telegrambot
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+type nopNotifier struct{}
+
+func (nopNotifier) Notify(notify.Event) error { return nil }
+
+// newRoutingBot creates a bot with an in-memory database, target 1 owned by
+// chat 10 and subscribed by chat 11, and notifiers "pager" and "hook".
+func newRoutingBot(t *testing.T) (*Bot, *Record) {
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1)
+	b := &Bot{DB: &TargetsDB{DB: db}}
+	b.DB.Migrate()
+
+	rec := &Record{ChatID: 10, Title: "Site", URL: "http://example.com/", Tags: "prod web"}
+	if err := b.DB.CreateTarget(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.DB.Subscribe(rec.ID, 11); err != nil {
+		t.Fatal(err)
+	}
+
+	pager, hook := nopNotifier{}, nopNotifier{}
+	b.Notifiers = []notify.Notifier{pager, hook}
+	b.NamedNotifiers = map[string]notify.Notifier{"pager": pager, "hook": hook}
+	return b, rec
+}
+
+// Monday, 2020-01-06 10:00 UTC.
+var routingTime = time.Date(2020, 1, 6, 10, 0, 0, 0, time.UTC)
+
+func TestRouteUpdateWithoutRules(t *testing.T) {
+	b, rec := newRoutingBot(t)
+
+	r := b.routeUpdate(rec, monitor.StatusTimeout, routingTime)
+	if len(r.Rules) != 0 || !r.AllNotifiers || !reflect.DeepEqual(r.Chats, []int64{10, 11}) {
+		t.Errorf("Unexpected default route: %+v", r)
+	}
+	if len(r.notifiers(b)) != 2 {
+		t.Errorf("Default route has %v notifiers, want 2", len(r.notifiers(b)))
+	}
+
+	b.DropUnrouted = true
+	r = b.routeUpdate(rec, monitor.StatusTimeout, routingTime)
+	if len(r.Chats) != 0 || r.AllNotifiers || len(r.notifiers(b)) != 0 {
+		t.Errorf("Unrouted update isn't dropped: %+v", r)
+	}
+}
+
+func TestRouteUpdate(t *testing.T) {
+	b, rec := newRoutingBot(t)
+	err := b.SetRoutingRules([]RoutingRule{
+		{Name: "page", Tags: []string{"PROD"}, Status: []string{"down"}, Notifiers: []string{"pager"}, Continue: true},
+		{Name: "ops", Tags: []string{"prod"}, Chats: []int64{20, 10}, Notifiers: []string{"pager", "hook"}},
+		{Name: "never", Chats: []int64{30}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		status    monitor.StatusType
+		rules     []string
+		chats     []int64
+		notifiers []string
+	}{
+		// Continue adds up destinations of both rules, the third is not
+		// evaluated after the stop.
+		{monitor.StatusTimeout, []string{"page", "ops"}, []int64{20, 10}, []string{"pager", "hook"}},
+		{monitor.StatusOK, []string{"ops"}, []int64{20, 10}, []string{"pager", "hook"}},
+	}
+	for _, c := range cases {
+		r := b.routeUpdate(rec, c.status, routingTime)
+		if !reflect.DeepEqual(r.Rules, c.rules) || !reflect.DeepEqual(r.Chats, c.chats) ||
+			!reflect.DeepEqual(r.Notifiers, c.notifiers) || r.AllNotifiers {
+			t.Errorf("Status %v: got %+v, want rules %v, chats %v, notifiers %v",
+				c.status, r, c.rules, c.chats, c.notifiers)
+		}
+	}
+
+	// Rules not matching the target fall through to the last one.
+	other := &Record{ChatID: 12, Title: "Other", URL: "http://example.org/"}
+	if err := b.DB.CreateTarget(other); err != nil {
+		t.Fatal(err)
+	}
+	r := b.routeUpdate(other, monitor.StatusTimeout, routingTime)
+	if !reflect.DeepEqual(r.Rules, []string{"never"}) || !reflect.DeepEqual(r.Chats, []int64{30}) || len(r.Notifiers) != 0 {
+		t.Errorf("Unexpected route of other target: %+v", r)
+	}
+}
+
+func TestRouteUpdateDropRule(t *testing.T) {
+	b, rec := newRoutingBot(t)
+	err := b.SetRoutingRules([]RoutingRule{
+		{Name: "office", Hours: "09:00-18:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}, TargetChats: true},
+		{Name: "drop"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := b.routeUpdate(rec, monitor.StatusTimeout, routingTime)
+	if !reflect.DeepEqual(r.Rules, []string{"office"}) || !reflect.DeepEqual(r.Chats, []int64{10, 11}) {
+		t.Errorf("Unexpected route in office hours: %+v", r)
+	}
+
+	for _, at := range []time.Time{
+		routingTime.Add(9 * time.Hour),
+		routingTime.AddDate(0, 0, 5),
+	} {
+		r = b.routeUpdate(rec, monitor.StatusTimeout, at)
+		if !reflect.DeepEqual(r.Rules, []string{"drop"}) || len(r.Chats) != 0 || len(r.notifiers(b)) != 0 {
+			t.Errorf("Update at %v isn't dropped: %+v", at, r)
+		}
+	}
+}
+
+func TestSetRoutingRulesValidates(t *testing.T) {
+	b, _ := newRoutingBot(t)
+	for _, rule := range []RoutingRule{
+		{Status: []string{"broken"}},
+		{Severity: []string{"high"}},
+		{Hours: "9-18"},
+		{Days: []string{"someday"}},
+		{Timezone: "Nowhere/City"},
+		{Notifiers: []string{"missing"}},
+	} {
+		if err := b.SetRoutingRules([]RoutingRule{rule}); err == nil {
+			t.Errorf("Rule %+v is accepted", rule)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package telegrambot
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// sessionKey identifies a dialog session. Each member of a group chat has
+// their own session, so several users may run dialogs in the same chat
+// without stealing each other's messages.
+type sessionKey struct {
+	ChatID int64
+	UserID int
+}
+
+func sessionKeyOf(msg *tgbotapi.Message) sessionKey {
+	key := sessionKey{ChatID: msg.Chat.ID}
+	if msg.From != nil {
+		key.UserID = msg.From.ID
+	}
+	return key
+}
+
+type session struct {
+	mu sync.Mutex
+	// Number of goroutines, which have acquired the session or are waiting
+	// for it. Guarded by sessionStore.mu.
+	refs int
+	// The dialog as it was last saved into the database, empty if none.
+	saved string
+
+	Stage      int
+	Dialog     dialog
+	LastActive time.Time
+}
+
+// savedDialog formats the persisted dialog to compare it with the saved one.
+func savedDialog(kind string, stage int, data string) string {
+	return fmt.Sprintf("%v %v %v", kind, stage, data)
+}
+
+// dialogKinds maps dialog kinds to constructors of empty dialogs. It's used
+// to restore persisted dialogs after restart.
+var dialogKinds = map[string]func(b *Bot) dialog{
+	"add":      func(b *Bot) dialog { return &addNewTarget{bot: b} },
+	"critical": func(b *Bot) dialog { return &markCritical{bot: b} },
+	"delete":   func(b *Bot) dialog { return &deleteTarget{bot: b} },
+	"edit":     func(b *Bot) dialog { return &editTarget{bot: b} },
+	"import":   func(b *Bot) dialog { return &importTargets{bot: b} },
+	"pause":    func(b *Bot) dialog { return &pauseTarget{bot: b} },
+	"restore":  func(b *Bot) dialog { return &restoreTarget{bot: b} },
+	"settings": func(b *Bot) dialog { return &changeSettings{bot: b} },
+}
+
+// sessionStore is a goroutine-safe collection of dialog sessions, which
+// mirrors sessions with a dialog in progress into the database.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[sessionKey]*session
+	bot      *Bot
+	// Serializes writes of sessions into the database, so that a session
+	// deleted by Expire can't overwrite a newer one with the same key.
+	dbMu sync.Mutex
+}
+
+func newSessionStore(b *Bot) *sessionStore {
+	return &sessionStore{
+		sessions: map[sessionKey]*session{},
+		bot:      b,
+	}
+}
+
+// Acquire returns the session of the key, creating it if necessary, and locks
+// it. The caller must call Release when it's done with the session.
+func (ss *sessionStore) Acquire(key sessionKey) *session {
+	ss.mu.Lock()
+	sess, ok := ss.sessions[key]
+	if !ok {
+		sess = &session{Stage: 1, LastActive: time.Now()}
+		ss.sessions[key] = sess
+	}
+	sess.refs++
+	ss.mu.Unlock()
+
+	sess.mu.Lock()
+	return sess
+}
+
+// Release saves the session's dialog into the database, if it has changed,
+// and unlocks the session.
+func (ss *sessionStore) Release(key sessionKey, sess *session) {
+	sess.LastActive = time.Now()
+	if err := ss.persist(key, sess); err != nil {
+		fmt.Println(err)
+	}
+	sess.mu.Unlock()
+
+	ss.mu.Lock()
+	sess.refs--
+	ss.mu.Unlock()
+}
+
+func (ss *sessionStore) persist(key sessionKey, sess *session) error {
+	if sess.Dialog == nil {
+		if sess.saved == "" {
+			return nil
+		}
+		ss.dbMu.Lock()
+		defer ss.dbMu.Unlock()
+		if err := ss.bot.DB.DeleteSession(key.ChatID, key.UserID); err != nil {
+			return err
+		}
+		sess.saved = ""
+		return nil
+	}
+
+	data, err := json.Marshal(sess.Dialog)
+	if err != nil {
+		return err
+	}
+	saved := savedDialog(sess.Dialog.Kind(), sess.Stage, string(data))
+	if saved == sess.saved {
+		return nil
+	}
+
+	ss.dbMu.Lock()
+	defer ss.dbMu.Unlock()
+	err = ss.bot.DB.SaveSession(SessionRecord{
+		ChatID:     key.ChatID,
+		UserID:     key.UserID,
+		Kind:       sess.Dialog.Kind(),
+		Stage:      sess.Stage,
+		Data:       string(data),
+		LastActive: sess.LastActive,
+	})
+	if err != nil {
+		return err
+	}
+	sess.saved = saved
+	return nil
+}
+
+// Load restores the dialogs persisted in the database. Dialogs, which have
+// been idle longer than timeout, are discarded.
+func (ss *sessionStore) Load(timeout time.Duration) error {
+	records, err := ss.bot.DB.GetSessions()
+	if err != nil {
+		return err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	for _, rec := range records {
+		key := sessionKey{ChatID: rec.ChatID, UserID: rec.UserID}
+
+		newDialog, ok := dialogKinds[rec.Kind]
+		if !ok || time.Since(rec.LastActive) > timeout {
+			ss.bot.DB.DeleteSession(rec.ChatID, rec.UserID)
+			continue
+		}
+
+		dlg := newDialog(ss.bot)
+		if err := json.Unmarshal([]byte(rec.Data), dlg); err != nil {
+			ss.bot.DB.DeleteSession(rec.ChatID, rec.UserID)
+			continue
+		}
+
+		ss.sessions[key] = &session{
+			Stage:      rec.Stage,
+			Dialog:     dlg,
+			LastActive: rec.LastActive,
+			saved:      savedDialog(rec.Kind, rec.Stage, rec.Data),
+		}
+	}
+
+	return nil
+}
+
+// Expire removes sessions, which have been idle longer than timeout, and
+// returns keys of those which had a dialog in progress. Sessions acquired by
+// someone are active and are skipped.
+func (ss *sessionStore) Expire(timeout time.Duration) []sessionKey {
+	var expired, saved []sessionKey
+
+	ss.mu.Lock()
+	for key, sess := range ss.sessions {
+		// Nobody holds the lock of a session without references, and nobody
+		// can acquire it while ss.mu is locked.
+		if sess.refs > 0 || time.Since(sess.LastActive) <= timeout {
+			continue
+		}
+		if sess.Dialog != nil {
+			expired = append(expired, key)
+		}
+		if sess.saved != "" {
+			saved = append(saved, key)
+		}
+		delete(ss.sessions, key)
+	}
+	// Sessions created after the unlock are saved after the deletion.
+	ss.dbMu.Lock()
+	ss.mu.Unlock()
+	defer ss.dbMu.Unlock()
+
+	for _, key := range saved {
+		if err := ss.bot.DB.DeleteSession(key.ChatID, key.UserID); err != nil {
+			fmt.Println(err)
+		}
+	}
+	return expired
+}
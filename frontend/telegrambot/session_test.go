@@ -0,0 +1,77 @@
+package telegrambot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSessionStorePersistsChanges(t *testing.T) {
+	b, _ := newRoutingBot(t)
+	ss := newSessionStore(b)
+	key := sessionKey{ChatID: 10, UserID: 1}
+
+	countSessions := func() int {
+		records, err := b.DB.GetSessions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(records)
+	}
+
+	sess := ss.Acquire(key)
+	ss.Release(key, sess)
+	if n := countSessions(); n != 0 {
+		t.Fatalf("%v sessions are saved without a dialog", n)
+	}
+
+	sess = ss.Acquire(key)
+	sess.Dialog = dialogKinds["pause"](b)
+	sess.Stage = 2
+	ss.Release(key, sess)
+	if n := countSessions(); n != 1 {
+		t.Fatalf("%v sessions are saved, want 1", n)
+	}
+
+	// An unchanged dialog isn't written again.
+	b.DB.DeleteSession(key.ChatID, key.UserID)
+	sess = ss.Acquire(key)
+	ss.Release(key, sess)
+	if n := countSessions(); n != 0 {
+		t.Fatalf("Unchanged session is saved")
+	}
+	sess = ss.Acquire(key)
+	sess.Stage = 3
+	ss.Release(key, sess)
+	if n := countSessions(); n != 1 {
+		t.Fatalf("Changed session isn't saved")
+	}
+}
+
+func TestSessionStoreExpire(t *testing.T) {
+	b, _ := newRoutingBot(t)
+	ss := newSessionStore(b)
+	idle, busy := sessionKey{ChatID: 10, UserID: 1}, sessionKey{ChatID: 10, UserID: 2}
+
+	for _, key := range []sessionKey{idle, busy} {
+		sess := ss.Acquire(key)
+		sess.Dialog = dialogKinds["pause"](b)
+		ss.Release(key, sess)
+		sess.LastActive = time.Now().Add(-time.Hour)
+	}
+
+	// Expire doesn't wait for the acquired session.
+	busySess := ss.Acquire(busy)
+	expired := ss.Expire(time.Minute)
+	if len(expired) != 1 || expired[0] != idle {
+		t.Errorf("Expired sessions are %v, want %v", expired, []sessionKey{idle})
+	}
+	ss.Release(busy, busySess)
+
+	records, err := b.DB.GetSessions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].UserID != busy.UserID {
+		t.Errorf("Unexpected saved sessions: %+v", records)
+	}
+}
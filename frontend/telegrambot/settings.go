@@ -0,0 +1,388 @@
+package telegrambot
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// chatSetting describes a single chat setting, which can be changed with
+// /settings command.
+type chatSetting struct {
+	Name        string
+	Description string
+	Get         func(s *ChatSettings) string
+	// Set parses the value and writes it into the settings. The returned error
+	// is shown to the user.
+	Set func(s *ChatSettings, value string) error
+}
+
+// Verbosity levels of notifications.
+const (
+	// VerbosityShort - a single line with the target and its status.
+	VerbosityShort = "short"
+	// VerbosityNormal - the status with its details.
+	VerbosityNormal = "normal"
+	// VerbosityFull - also the previous status and the HTTP status of OK
+	// responses.
+	VerbosityFull = "full"
+)
+
+func formatBool(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "yes", "true", "1":
+		return true, nil
+	case "off", "no", "false", "0":
+		return false, nil
+	}
+	return false, errorf("Invalid value %q, use on or off", value)
+}
+
+// boolSetting constructs a chatSetting for a boolean field.
+// If inverted is true, the field is set to the opposite of the value.
+func boolSetting(name, description string, field func(s *ChatSettings) *bool, inverted bool) chatSetting {
+	return chatSetting{
+		Name:        name,
+		Description: description,
+		Get: func(s *ChatSettings) string {
+			return formatBool(*field(s) != inverted)
+		},
+		Set: func(s *ChatSettings, value string) error {
+			b, err := parseBool(value)
+			if err != nil {
+				return err
+			}
+			*field(s) = b != inverted
+			return nil
+		},
+	}
+}
+
+var chatSettingsList = []chatSetting{
+	{
+		Name:        "policy",
+		Description: "who can add, edit and delete targets: everyone, admins or allowlist",
+		Get:         func(s *ChatSettings) string { return s.ManagePolicy },
+		Set: func(s *ChatSettings, value string) error {
+			if !isValidPolicy(value) {
+				return errorf("Unknown policy %q, use everyone, admins or allowlist", value)
+			}
+			s.ManagePolicy = value
+			return nil
+		},
+	},
+	boolSetting(
+		"first_ok", "notify about the first status of a new target even if it's OK",
+		func(s *ChatSettings) *bool { return &s.NotifyFirstOK }, false),
+	boolSetting(
+		"recovery", "notify when a target goes up again",
+		func(s *ChatSettings) *bool { return &s.MuteRecovery }, true),
+	boolSetting(
+		"silent_recovery", "send recovery notifications without sound",
+		func(s *ChatSettings) *bool { return &s.SilentRecovery }, false),
+	boolSetting(
+		"details", "include response time, error and HTTP status into notifications",
+		func(s *ChatSettings) *bool { return &s.HideDetails }, true),
+	{
+		Name:        "language",
+		Description: "language of the bot's messages: en, ru or auto (the language of the members' Telegram)",
+		Get: func(s *ChatSettings) string {
+			if s.Language == "" {
+				return "auto"
+			}
+			return s.Language
+		},
+		Set: func(s *ChatSettings, value string) error {
+			if value == "auto" {
+				s.Language = ""
+				return nil
+			}
+			lang := normalizeLanguage(value)
+			if lang == "" {
+				return errorf("Unknown language %q, use en, ru or auto", value)
+			}
+			s.Language = lang
+			return nil
+		},
+	},
+	{
+		Name:        "timezone",
+		Description: "time zone of displayed times, e.g. Europe/Moscow",
+		Get:         func(s *ChatSettings) string { return s.Location().String() },
+		Set: func(s *ChatSettings, value string) error {
+			if _, err := time.LoadLocation(value); err != nil || value == "" {
+				return errorf("Unknown time zone %q", value)
+			}
+			s.Timezone = value
+			return nil
+		},
+	},
+	{
+		Name:        "verbosity",
+		Description: "how detailed notifications are: short, normal or full",
+		Get: func(s *ChatSettings) string {
+			if s.Verbosity == "" {
+				return VerbosityNormal
+			}
+			return s.Verbosity
+		},
+		Set: func(s *ChatSettings, value string) error {
+			switch value {
+			case VerbosityShort, VerbosityNormal, VerbosityFull:
+				s.Verbosity = value
+				return nil
+			}
+			return errorf("Unknown verbosity %q, use short, normal or full", value)
+		},
+	},
+	{
+		Name:        "quiet_hours",
+		Description: "daily interval like 23:00-07:00 when only critical targets notify loudly, or off",
+		Get: func(s *ChatSettings) string {
+			if s.QuietHours == "" {
+				return "off"
+			}
+			return s.QuietHours
+		},
+		Set: func(s *ChatSettings, value string) error {
+			if value == "off" {
+				s.QuietHours = ""
+				return nil
+			}
+			if _, err := parseQuietHours(value); err != nil {
+				return err
+			}
+			s.QuietHours = value
+			return nil
+		},
+	},
+	{
+		Name:        "quiet_mode",
+		Description: "what to do with notifications during quiet hours: silent or queue (send a digest afterwards)",
+		Get: func(s *ChatSettings) string {
+			if s.QuietMode == "" {
+				return QuietModeSilent
+			}
+			return s.QuietMode
+		},
+		Set: func(s *ChatSettings, value string) error {
+			switch value {
+			case QuietModeSilent, QuietModeQueue:
+				s.QuietMode = value
+				return nil
+			}
+			return errorf("Unknown quiet mode %q, use silent or queue", value)
+		},
+	},
+	{
+		Name:        "report",
+		Description: "when to send uptime reports: daily HH:MM, weekly mon HH:MM or off",
+		Get: func(s *ChatSettings) string {
+			if s.ReportSchedule == "" {
+				return "off"
+			}
+			return s.ReportSchedule
+		},
+		Set: func(s *ChatSettings, value string) error {
+			if value == "off" {
+				s.ReportSchedule = ""
+				return nil
+			}
+			if _, err := parseReportSchedule(value); err != nil {
+				return err
+			}
+			s.ReportSchedule = strings.ToLower(value)
+			// Don't send the report, which was due before the schedule was set.
+			s.LastReportAt = time.Now()
+			return nil
+		},
+	},
+}
+
+func findChatSetting(name string) (chatSetting, bool) {
+	for _, setting := range chatSettingsList {
+		if setting.Name == name {
+			return setting, true
+		}
+	}
+	return chatSetting{}, false
+}
+
+// getChatSettings returns the chat's settings, falling back to the bot's
+// defaults if the chat has never changed them.
+func (b *Bot) getChatSettings(chatID int64) (ChatSettings, error) {
+	settings, ok, err := b.DB.GetChatSettings(chatID)
+	if err != nil {
+		return ChatSettings{}, err
+	}
+	if !ok {
+		settings = b.DefaultSettings
+		settings.ID = 0
+		settings.ChatID = chatID
+	}
+	if settings.ManagePolicy == "" {
+		settings.ManagePolicy = PolicyEveryone
+	}
+	return settings, nil
+}
+
+func (b *Bot) formatChatSettings(chatID int64) (string, error) {
+	settings, err := b.getChatSettings(chatID)
+	if err != nil {
+		return "", err
+	}
+
+	lang := b.languageOf(settings)
+	var lines []string
+	lines = append(lines, translate(lang, "Settings of this chat:")+"\n")
+	for _, setting := range chatSettingsList {
+		lines = append(lines, fmt.Sprintf(
+			"<b>%v</b> = %v\n    <i>%v</i>",
+			setting.Name, replaceHTML(setting.Get(&settings)), translate(lang, setting.Description)))
+	}
+
+	users, err := b.DB.GetAllowedUsers(chatID)
+	if err != nil {
+		return "", err
+	}
+	var userStrings []string
+	for _, user := range users {
+		if user.Username != "" {
+			userStrings = append(userStrings, fmt.Sprintf("@%v (%v)", replaceHTML(user.Username), user.UserID))
+		} else {
+			userStrings = append(userStrings, fmt.Sprintf("%v", user.UserID))
+		}
+	}
+	if len(userStrings) == 0 {
+		userStrings = append(userStrings, translate(lang, "nobody"))
+	}
+	lines = append(lines, fmt.Sprintf("<b>allowlist</b>: %v", strings.Join(userStrings, ", ")))
+
+	return strings.Join(lines, "\n"), nil
+}
+
+// applySetting parses a "name value" string and applies it to the chat's
+// settings. Besides the settings from chatSettingsList it understands
+// "allow USER_ID" and "disallow USER_ID"; "allow" without an ID in a reply
+// to a message allows the author of that message.
+// It returns a message for the user and false if the input was invalid.
+func (b *Bot) applySetting(msg *tgbotapi.Message, text string) (string, bool) {
+	lang := b.chatLanguage(msg.Chat.ID)
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return translate(lang, "Please send the name of a setting and its value"), false
+	}
+	name := strings.ToLower(fields[0])
+	value := strings.Join(fields[1:], " ")
+
+	internalError := func(err error) (string, bool) {
+		fmt.Println(err)
+		return translate(
+			lang,
+			"Error while saving the settings, please contact the administrator: %v",
+			b.AdminNickname), true
+	}
+
+	if name == "allow" || name == "disallow" {
+		user := AllowedUser{ChatID: msg.Chat.ID}
+		reply := msg.ReplyToMessage
+		if value == "" && reply != nil && reply.From != nil && reply.From.ID != b.TgBot.Self.ID {
+			user.UserID = reply.From.ID
+			user.Username = reply.From.UserName
+		} else {
+			id, err := strconv.Atoi(strings.TrimPrefix(value, "@"))
+			if err != nil {
+				return translate(lang, "Invalid user ID, please try again"), false
+			}
+			user.UserID = id
+		}
+
+		var err error
+		if name == "allow" {
+			err = b.DB.AllowUser(user)
+		} else {
+			err = b.DB.DisallowUser(user.ChatID, user.UserID)
+		}
+		if err != nil {
+			return internalError(err)
+		}
+		return translate(lang, "Allowlist was successfully updated"), true
+	}
+
+	setting, ok := findChatSetting(name)
+	if !ok {
+		return translate(lang, "Unknown setting %q, please try again", name), false
+	}
+
+	settings, err := b.getChatSettings(msg.Chat.ID)
+	if err != nil {
+		return internalError(err)
+	}
+	if err := setting.Set(&settings, value); err != nil {
+		return replaceHTML(translateError(lang, err)), false
+	}
+	if err := b.DB.SaveChatSettings(settings); err != nil {
+		return internalError(err)
+	}
+	// The reply is in the new language if it's the one changed.
+	lang = b.languageOf(settings)
+	return translate(
+		lang, "<b>%v</b> is now set to %v",
+		setting.Name, replaceHTML(setting.Get(&settings))), true
+}
+
+type changeSettings struct {
+	bot *Bot
+}
+
+func (t *changeSettings) Kind() string { return "settings" }
+
+func (t *changeSettings) ContinueDialog(stepNumber int, update tgbotapi.Update, bot *tgbotapi.BotAPI) (int, bool) {
+	if stepNumber == 1 {
+		if args := update.Message.CommandArguments(); args != "" {
+			reply, _ := t.bot.applySetting(update.Message, args)
+			t.bot.SendMessage(update.Message.Chat.ID, reply)
+			return 0, false
+		}
+
+		text, err := t.bot.formatChatSettings(update.Message.Chat.ID)
+		if err != nil {
+			fmt.Println(err)
+			t.bot.SendMessage(
+				update.Message.Chat.ID,
+				t.bot.tr(
+					update.Message.Chat.ID,
+					"Error while retrieving the settings, please contact the administrator: %v",
+					t.bot.AdminNickname))
+			return 0, false
+		}
+		text += "\n\n" + t.bot.tr(
+			update.Message.Chat.ID,
+			"Send the name of a setting and its new value, e.g. <code>policy admins</code>. "+
+				"Use <code>allow USER_ID</code> and <code>disallow USER_ID</code> to edit the allowlist "+
+				"or reply to a member's message with <code>/settings allow</code>. "+
+				"Send /cancel if you've changed your mind.")
+		t.bot.SendDialogMessage(update.Message, text)
+		return 2, true
+	}
+	if stepNumber == 2 {
+		reply, ok := t.bot.applySetting(update.Message, update.Message.Text)
+		if !ok {
+			t.bot.SendDialogMessage(update.Message, reply)
+			return 2, true
+		}
+		t.bot.SendMessage(update.Message.Chat.ID, reply)
+		return 0, false
+	}
+	return 0, false
+}
@@ -0,0 +1,233 @@
+package telegrambot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/jinzhu/gorm"
+)
+
+// newShareToken generates a random token for a share link. Telegram allows
+// up to 64 characters A-Z, a-z, 0-9, _ and - in start parameters.
+func newShareToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// getOwnedTarget parses the target ID and returns the target if it belongs
+// to the message's chat. Otherwise it replies with an error and returns nil.
+func (b *Bot) getOwnedTarget(message *tgbotapi.Message, text string) *Record {
+	id, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil {
+		b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "Invalid ID"))
+		return nil
+	}
+	target, err := b.DB.GetTarget(id)
+	if err != nil || target.ChatID != message.Chat.ID {
+		b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "No target with such ID found"))
+		return nil
+	}
+	return target
+}
+
+// isVisibleTo reports whether the chat owns or is subscribed to the target.
+func (b *Bot) isVisibleTo(target *Record, chatID int64) bool {
+	if target.ChatID == chatID {
+		return true
+	}
+	subscribed, err := b.DB.IsSubscribed(target.ID, chatID)
+	if err != nil {
+		fmt.Println(err)
+	}
+	return subscribed
+}
+
+// shareTarget handles /share command with argument "ID". It replies with
+// links, which subscribe another chat to the target.
+func (b *Bot) shareTarget(message *tgbotapi.Message) {
+	if message.CommandArguments() == "" {
+		b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "Usage: /share ID"))
+		return
+	}
+	target := b.getOwnedTarget(message, message.CommandArguments())
+	if target == nil {
+		return
+	}
+
+	if target.ShareToken == "" {
+		token, err := newShareToken()
+		if err == nil {
+			target.ShareToken = token
+			err = b.DB.UpdateTarget(*target)
+		}
+		if err != nil {
+			fmt.Println(err)
+			b.SendMessage(
+				message.Chat.ID,
+				b.tr(
+					message.Chat.ID,
+					"Error while sharing the target, please contact the administrator: %v",
+					b.AdminNickname))
+			return
+		}
+	}
+
+	b.SendMessage(message.Chat.ID, b.tr(
+		message.Chat.ID,
+		"Use these links to receive notifications about <b>%v</b> in another chat.\n\n"+
+			"Private chat: https://t.me/%v?start=%v\n"+
+			"Group: https://t.me/%v?startgroup=%v\n\n"+
+			"Only this chat can edit or delete the target. Use /unshare %v to revoke the links.",
+		replaceHTML(target.Title),
+		b.TgBot.Self.UserName, target.ShareToken,
+		b.TgBot.Self.UserName, target.ShareToken,
+		target.ID))
+}
+
+// unshareTarget handles /unshare command with argument "ID". It revokes
+// the target's share links, chats subscribed already stay subscribed.
+func (b *Bot) unshareTarget(message *tgbotapi.Message) {
+	if message.CommandArguments() == "" {
+		b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "Usage: /unshare ID"))
+		return
+	}
+	target := b.getOwnedTarget(message, message.CommandArguments())
+	if target == nil {
+		return
+	}
+
+	target.ShareToken = ""
+	if err := b.DB.UpdateTarget(*target); err != nil {
+		fmt.Println(err)
+		b.SendMessage(
+			message.Chat.ID,
+			b.tr(
+				message.Chat.ID,
+				"Error while editing the target, please contact the administrator: %v",
+				b.AdminNickname))
+		return
+	}
+	b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "Share links of the target were revoked"))
+}
+
+// subscribeByToken handles /start command with the payload of a share link.
+func (b *Bot) subscribeByToken(message *tgbotapi.Message, token string) {
+	if !b.checkPermission(message, b.canManageTargets) {
+		return
+	}
+
+	target, err := b.DB.GetTargetByShareToken(token)
+	if err == gorm.ErrRecordNotFound {
+		b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "This link is invalid or has been revoked"))
+		return
+	}
+	if err == nil && target.ChatID == message.Chat.ID {
+		b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "This target belongs to this chat already"))
+		return
+	}
+	if err == nil {
+		err = b.DB.Subscribe(target.ID, message.Chat.ID)
+	}
+	if err != nil {
+		fmt.Println(err)
+		b.SendMessage(
+			message.Chat.ID,
+			b.tr(
+				message.Chat.ID,
+				"Error while subscribing to the target, please contact the administrator: %v",
+				b.AdminNickname))
+		return
+	}
+
+	b.SendMessage(message.Chat.ID, b.tr(
+		message.Chat.ID,
+		"This chat is now subscribed to <b>%v</b> (%v). Use /unsubscribe %v to stop receiving notifications.",
+		replaceHTML(target.Title), replaceHTML(target.URL), target.ID))
+}
+
+// unsubscribe handles /unsubscribe command with argument "ID". Without
+// argument it lists the chat's subscriptions.
+func (b *Bot) unsubscribe(message *tgbotapi.Message) {
+	internalError := func(err error) {
+		fmt.Println(err)
+		b.SendMessage(
+			message.Chat.ID,
+			b.tr(
+				message.Chat.ID,
+				"Error while retrieving the targets, please contact the administrator: %v",
+				b.AdminNickname))
+	}
+
+	if message.CommandArguments() == "" {
+		targs, err := b.DB.GetSubscribedTargets(message.Chat.ID)
+		if err != nil {
+			internalError(err)
+			return
+		}
+		if len(targs) == 0 {
+			b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "This chat has no subscriptions"))
+			return
+		}
+		lines := []string{b.tr(message.Chat.ID, "Usage: /unsubscribe ID") + "\n"}
+		for _, target := range targs {
+			lines = append(lines, fmt.Sprintf(
+				"<b>%v</b>: <a href=\"%v\">%v</a>",
+				target.ID, replaceHTML(target.URL), replaceHTML(target.Title)))
+		}
+		b.SendMessage(message.Chat.ID, strings.Join(lines, "\n"))
+		return
+	}
+
+	id, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "Invalid ID"))
+		return
+	}
+	subscribed, err := b.DB.IsSubscribed(uint(id), message.Chat.ID)
+	if err != nil {
+		internalError(err)
+		return
+	}
+	if !subscribed {
+		b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "This chat is not subscribed to such target"))
+		return
+	}
+	if err := b.DB.Unsubscribe(uint(id), message.Chat.ID); err != nil {
+		internalError(err)
+		return
+	}
+	b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "Unsubscribed"))
+}
+
+// targetChats returns the chat owning the target followed by the chats
+// subscribed to it.
+func (b *Bot) targetChats(target *Record) []int64 {
+	subscribers, err := b.DB.GetSubscribers(target.ID)
+	if err != nil {
+		fmt.Println(err)
+	}
+	return append([]int64{target.ChatID}, subscribers...)
+}
+
+// notifySubscribersOfDeletion tells the target's subscribers that its owner
+// has deleted it.
+func (b *Bot) notifySubscribersOfDeletion(target *Record) {
+	chats, err := b.DB.GetSubscribers(target.ID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for _, chatID := range chats {
+		b.SendMessage(chatID, b.tr(
+			chatID,
+			"<b>%v</b> (%v) was deleted by the chat owning it, notifications about it are stopped",
+			replaceHTML(target.Title), replaceHTML(target.URL)))
+	}
+}
@@ -0,0 +1,184 @@
+package telegrambot
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const maxTagLength = 32
+
+// parseTags splits the text into tags. Tags are case-insensitive, may be
+// prefixed with "#" and consist of letters, digits, "-" and "_".
+func parseTags(text string) ([]string, error) {
+	var tags []string
+	seen := map[string]bool{}
+	for _, field := range strings.Fields(text) {
+		tag := strings.ToLower(strings.TrimPrefix(field, "#"))
+		if tag == "" || len([]rune(tag)) > maxTagLength {
+			return nil, errorf("Invalid tag %q", field)
+		}
+		for _, r := range tag {
+			if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+				return nil, errorf("Invalid tag %q: only letters, digits, - and _ are allowed", field)
+			}
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// TagList returns the target's tags.
+func (r *Record) TagList() []string {
+	return strings.Fields(r.Tags)
+}
+
+func (r *Record) HasTag(tag string) bool {
+	for _, t := range r.TagList() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Record) setTags(tags []string) {
+	sort.Strings(tags)
+	r.Tags = strings.Join(tags, " ")
+}
+
+// formatTags formats tags as hashtags, so that they are clickable.
+func formatTags(tags []string) string {
+	var hashtags []string
+	for _, tag := range tags {
+		hashtags = append(hashtags, "#"+tag)
+	}
+	return replaceHTML(strings.Join(hashtags, " "))
+}
+
+// targetGroup is a titled list of targets in /targets output.
+type targetGroup struct {
+	Title   string
+	Targets []Record
+}
+
+// groupTargetsByTag puts each target in the group of each of its tags.
+// Groups are ordered by tag, untagged targets go last.
+func groupTargetsByTag(targs []Record) []targetGroup {
+	byTag := map[string][]Record{}
+	var untagged []Record
+	for _, target := range targs {
+		tags := target.TagList()
+		if len(tags) == 0 {
+			untagged = append(untagged, target)
+		}
+		for _, tag := range tags {
+			byTag[tag] = append(byTag[tag], target)
+		}
+	}
+
+	var tags []string
+	for tag := range byTag {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	var groups []targetGroup
+	for _, tag := range tags {
+		groups = append(groups, targetGroup{"#" + tag, byTag[tag]})
+	}
+	if len(untagged) > 0 {
+		groups = append(groups, targetGroup{"Untagged", untagged})
+	}
+	return groups
+}
+
+// getTargetsByTag returns the chat's targets with the tag.
+func (b *Bot) getTargetsByTag(chatID int64, tag string) ([]Record, error) {
+	targs, err := b.DB.GetCurrentTargets(chatID)
+	if err != nil {
+		return nil, err
+	}
+	var tagged []Record
+	for _, target := range targs {
+		if target.HasTag(tag) {
+			tagged = append(tagged, target)
+		}
+	}
+	return tagged, nil
+}
+
+// changeTags handles /tag and /untag commands with arguments "ID tag...".
+func (b *Bot) changeTags(message *tgbotapi.Message, add bool) {
+	lang := b.chatLanguage(message.Chat.ID)
+	args := strings.Fields(message.CommandArguments())
+	if len(args) < 2 {
+		b.SendMessage(message.Chat.ID, translate(lang, "Usage: /%v ID tag...", message.Command()))
+		return
+	}
+
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		b.SendMessage(message.Chat.ID, translate(lang, "Invalid ID"))
+		return
+	}
+	target, err := b.DB.GetTarget(id)
+	if err != nil || target.ChatID != message.Chat.ID {
+		b.SendMessage(message.Chat.ID, translate(lang, "No target with such ID found"))
+		return
+	}
+	changed, err := parseTags(strings.Join(args[1:], " "))
+	if err != nil {
+		b.SendMessage(message.Chat.ID, replaceHTML(translateError(lang, err)))
+		return
+	}
+
+	before := *target
+	var tags []string
+	for _, tag := range target.TagList() {
+		if add || !containsString(changed, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	if add {
+		for _, tag := range changed {
+			if !target.HasTag(tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	target.setTags(tags)
+
+	err = b.DB.UpdateTarget(*target)
+	if err != nil {
+		b.SendMessage(
+			message.Chat.ID,
+			translate(
+				lang,
+				"Error while editing the target, please contact the administrator: %v",
+				b.AdminNickname))
+		return
+	}
+	b.audit(message, ActionEdit, &before, target)
+
+	if len(tags) == 0 {
+		b.SendMessage(message.Chat.ID, translate(lang, "Target has no tags now"))
+	} else {
+		b.SendMessage(message.Chat.ID, translate(lang, "Target's tags: %v", formatTags(tags)))
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
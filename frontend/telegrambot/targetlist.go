@@ -0,0 +1,392 @@
+package telegrambot
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// Maximum length of a message text accepted by Telegram.
+const maxMessageLength = 4096
+
+// messageLength returns the length of the text as Telegram counts it,
+// in UTF-16 code units.
+func messageLength(text string) int {
+	length := 0
+	for _, r := range text {
+		if r > 0xffff {
+			length += 2
+		} else {
+			length++
+		}
+	}
+	return length
+}
+
+// truncateMessage cuts the text to the length limit, without cutting
+// through an HTML tag or entity.
+func truncateMessage(text string, limit int) string {
+	length := 0
+	for i, r := range text {
+		length += messageLength(string(r))
+		if length > limit {
+			text = text[:i]
+			if lt := strings.LastIndex(text, "<"); lt > strings.LastIndex(text, ">") {
+				text = text[:lt]
+			}
+			if amp := strings.LastIndex(text, "&"); amp > strings.LastIndex(text, ";") {
+				text = text[:amp]
+			}
+			return text
+		}
+	}
+	return text
+}
+
+// htmlTag is a tag left open in a part of a split message.
+type htmlTag struct {
+	Name string
+	// The opening tag with attributes, e.g. <a href="...">.
+	Open string
+}
+
+// updateOpenTags returns the tags left open after the text, given the ones
+// open before it.
+func updateOpenTags(open []htmlTag, text string) []htmlTag {
+	open = append([]htmlTag(nil), open...)
+	for _, match := range htmlTagRegexp.FindAllStringSubmatch(text, -1) {
+		name := strings.ToLower(match[2])
+		if match[1] != "/" {
+			open = append(open, htmlTag{Name: name, Open: match[0]})
+			continue
+		}
+		for i := len(open) - 1; i >= 0; i-- {
+			if open[i].Name == name {
+				open = open[:i]
+				break
+			}
+		}
+	}
+	return open
+}
+
+func openingTags(tags []htmlTag) string {
+	var s string
+	for _, tag := range tags {
+		s += tag.Open
+	}
+	return s
+}
+
+func closingTags(tags []htmlTag) string {
+	var s string
+	for i := len(tags) - 1; i >= 0; i-- {
+		s += "</" + tags[i].Name + ">"
+	}
+	return s
+}
+
+// truncateLine cuts the line, so that it fits into limit along with the
+// closing tags. Tags opened and closed in the cut off text are closed, tags
+// opened there and left open are reopened, so that the tags open after the
+// line stay the same.
+func truncateLine(line string, open []htmlTag, limit int) (string, []htmlTag) {
+	full := updateOpenTags(open, line)
+	budget := limit
+	for {
+		cut := truncateMessage(line, budget)
+		cutOpen := updateOpenTags(open, cut)
+		common := 0
+		for common < len(cutOpen) && common < len(full) && cutOpen[common] == full[common] {
+			common++
+		}
+		cut += closingTags(cutOpen[common:]) + openingTags(full[common:])
+		over := messageLength(cut) + messageLength(closingTags(full)) - limit
+		if over <= 0 || budget <= 0 {
+			return cut, full
+		}
+		budget -= over
+		if budget < 0 {
+			budget = 0
+		}
+	}
+}
+
+// splitMessage splits the text into parts no longer than limit. The text is
+// split by lines, tags open at a split are closed at the end of the part and
+// reopened at the start of the next one. Lines longer than limit are cut.
+func splitMessage(text string, limit int) []string {
+	if messageLength(text) <= limit {
+		return []string{text}
+	}
+
+	var parts []string
+	var current []string
+	var open []htmlTag
+	// Tags reopened at the start of the current part.
+	prefix := ""
+	currentLength := 0
+	flush := func() {
+		part := strings.Trim(strings.Join(current, "\n"), "\n")
+		if part != "" {
+			parts = append(parts, prefix+part+closingTags(open))
+		}
+		current = nil
+		prefix = openingTags(open)
+		currentLength = messageLength(prefix)
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		next := updateOpenTags(open, line)
+		length := messageLength(line)
+		if len(current) > 0 && currentLength+1+length+messageLength(closingTags(next)) > limit {
+			flush()
+		}
+		if currentLength+length+messageLength(closingTags(next)) > limit {
+			line, next = truncateLine(line, open, limit-currentLength)
+			length = messageLength(line)
+		}
+		if len(current) > 0 {
+			currentLength++
+		}
+		current = append(current, line)
+		currentLength += length
+		open = next
+	}
+	flush()
+	return parts
+}
+
+// Orders of /targets output.
+const (
+	TargetsSortDefault = ""
+	TargetsSortName    = "name"
+	TargetsSortStatus  = "status"
+	TargetsSortLatency = "latency"
+)
+
+// targetListItem is a target with its current status.
+type targetListItem struct {
+	Record
+	Status    monitor.Status
+	HasStatus bool
+}
+
+func (item *targetListItem) isFailing() bool {
+	return !item.Paused && item.HasStatus && item.Status.Type != monitor.StatusOK
+}
+
+// statusRank orders items by status: failing first, then without status,
+// paused and OK.
+func (item *targetListItem) statusRank() int {
+	switch {
+	case item.isFailing():
+		return 0
+	case item.Paused:
+		return 2
+	case !item.HasStatus:
+		return 1
+	}
+	return 3
+}
+
+// sortTargetListItems sorts the items in place. Ties are broken by title.
+func sortTargetListItems(items []targetListItem, order string) {
+	if order == TargetsSortDefault {
+		return
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := &items[i], &items[j]
+		switch order {
+		case TargetsSortStatus:
+			if a.statusRank() != b.statusRank() {
+				return a.statusRank() < b.statusRank()
+			}
+		case TargetsSortLatency:
+			// Targets without response time go last.
+			aHas := a.HasStatus && !a.Paused
+			bHas := b.HasStatus && !b.Paused
+			if aHas != bHas {
+				return aHas
+			}
+			if a.Status.ResponseTime != b.Status.ResponseTime {
+				return a.Status.ResponseTime > b.Status.ResponseTime
+			}
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+	})
+}
+
+// formatTargetLine formats a target with its status for /targets output.
+// Targets of other chats are marked as shared.
+func formatTargetLine(lang string, item targetListItem, chatID int64) string {
+	header := fmt.Sprintf(
+		"<a href=\"%v\">%v</a>",
+		replaceHTML(item.URL), replaceHTML(item.Title))
+	if item.ChatID != chatID {
+		header += translate(lang, " (shared)")
+	}
+
+	if item.Paused {
+		return translate(lang, "%v: paused", header)
+	}
+	if !item.HasStatus {
+		return fmt.Sprintf("%v: N/A", header)
+	}
+
+	var emoji string
+	if item.Status.Type == monitor.StatusOK {
+		emoji = okStatusEmoji
+	} else {
+		emoji = errorStatusEmoji
+	}
+	return translate(
+		lang,
+		"%v: %v %v (%v ms)",
+		header, emoji, translate(lang, item.Status.Type.String()), int64(item.Status.ResponseTime/time.Millisecond))
+}
+
+// targetListOptions are parsed arguments of /targets command.
+type targetListOptions struct {
+	// Show only targets with this tag, if not empty.
+	Tag string
+	// One of TargetsSort* constants.
+	Order string
+	// Show only failing targets.
+	FailingOnly bool
+}
+
+// parseTargetListOptions parses arguments like "prod latency failing".
+// Keywords are recognized in any order, any other word is a tag. A tag
+// coinciding with a keyword must be prefixed with "#".
+func parseTargetListOptions(args string) (targetListOptions, error) {
+	var opts targetListOptions
+	for _, word := range strings.Fields(strings.ToLower(args)) {
+		switch word {
+		case TargetsSortName, TargetsSortStatus, TargetsSortLatency:
+			opts.Order = word
+			continue
+		case "failing":
+			opts.FailingOnly = true
+			continue
+		}
+
+		tags, err := parseTags(word)
+		if err != nil {
+			return opts, err
+		}
+		if opts.Tag != "" {
+			return opts, errorf("Only one tag can be given")
+		}
+		opts.Tag = tags[0]
+	}
+	return opts, nil
+}
+
+// sendTargetList handles /targets command. The list is grouped by tags and
+// is split in several messages if it's too long.
+func (b *Bot) sendTargetList(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	opts, err := parseTargetListOptions(message.CommandArguments())
+	if err != nil {
+		b.SendMessage(
+			message.Chat.ID,
+			replaceHTML(translateError(lang, err))+"\n\n"+
+				translate(lang, "Usage: /targets [tag] [name|status|latency] [failing]"))
+		return
+	}
+
+	targs, err := b.DB.GetVisibleTargets(message.Chat.ID)
+	if err != nil {
+		b.SendMessage(
+			message.Chat.ID,
+			translate(
+				lang,
+				"Error while retrieving the targets, please contact the administrator: %v",
+				b.AdminNickname))
+		return
+	}
+	if len(targs) == 0 {
+		b.SendMessage(message.Chat.ID, translate(lang, "No targets! Use /add to add one."))
+		return
+	}
+
+	var items []targetListItem
+	for _, target := range targs {
+		if opts.Tag != "" && !target.HasTag(opts.Tag) {
+			continue
+		}
+		item := targetListItem{Record: target}
+		if !target.Paused {
+			item.Status, item.HasStatus, err = b.Monitor.StatusStore.GetStatus(target.ToTarget())
+			if err != nil {
+				fmt.Println(err)
+				b.SendMessage(
+					message.Chat.ID,
+					translate(
+						lang,
+						"Error while retrieving the target's status, please contact the administrator: %v",
+						b.AdminNickname))
+				return
+			}
+		}
+		if opts.FailingOnly && !item.isFailing() {
+			continue
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		switch {
+		case opts.FailingOnly:
+			b.SendMessage(message.Chat.ID, translate(lang, "All targets are up"))
+		default:
+			b.SendMessage(message.Chat.ID, translate(lang, "No targets with this tag"))
+		}
+		return
+	}
+	sortTargetListItems(items, opts.Order)
+
+	var groups []targetGroup
+	if opts.Tag != "" {
+		groups = []targetGroup{{Title: "#" + opts.Tag}}
+		for _, item := range items {
+			groups[0].Targets = append(groups[0].Targets, item.Record)
+		}
+	} else {
+		var records []Record
+		for _, item := range items {
+			records = append(records, item.Record)
+		}
+		groups = groupTargetsByTag(records)
+		// Without any tags grouping is pointless.
+		if len(groups) == 1 && groups[0].Title == "Untagged" {
+			groups[0].Title = ""
+		}
+	}
+
+	byID := map[uint]targetListItem{}
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	var lines []string
+	if opts.FailingOnly {
+		lines = append(lines, translate(lang, "<b>%v of %v targets are failing</b>", len(items), len(targs)))
+	}
+	for _, group := range groups {
+		if group.Title != "" {
+			if len(lines) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, fmt.Sprintf("<b>%v</b>", replaceHTML(translate(lang, group.Title))))
+		}
+		for _, target := range group.Targets {
+			lines = append(lines, formatTargetLine(lang, byID[target.ID], message.Chat.ID))
+		}
+	}
+	b.SendMessage(message.Chat.ID, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,73 @@
+package telegrambot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTruncateMessage(t *testing.T) {
+	cases := []struct {
+		text  string
+		limit int
+		want  string
+	}{
+		{"short", 10, "short"},
+		{"abcdef", 3, "abc"},
+		{"ab<b>cd</b>", 4, "ab"},
+		{"ab<a href=\"x\">cd</a>", 10, "ab"},
+		{"a &amp; b", 4, "a "},
+		{"a &amp; b", 7, "a &amp;"},
+		{"\U0001F600\U0001F600", 3, "\U0001F600"},
+	}
+	for _, c := range cases {
+		if got := truncateMessage(c.text, c.limit); got != c.want {
+			t.Errorf("truncateMessage(%q, %v) = %q, want %q", c.text, c.limit, got, c.want)
+		}
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	cases := []struct {
+		text  string
+		limit int
+		want  []string
+	}{
+		{"one\ntwo", 100, []string{"one\ntwo"}},
+		{"one\ntwo\nthree", 8, []string{"one\ntwo", "three"}},
+		{"one\n\n\ntwo", 5, []string{"one", "two"}},
+		// Tags open at a split are closed and reopened.
+		{"<pre>one\ntwo\nthree</pre>", 20, []string{"<pre>one\ntwo</pre>", "<pre>three</pre>"}},
+		{"<b>a</b> <i>x\n<u>y</u>\nz</i>", 17, []string{"<b>a</b> <i>x</i>", "<i><u>y</u>\nz</i>"}},
+		{"<a href=\"http://x\">link\ntext</a>", 30, []string{"<a href=\"http://x\">link</a>", "<a href=\"http://x\">text</a>"}},
+		// Long lines are cut, closing tags opened in the cut line.
+		{"<b>abcdefgh</b>\nnext", 10, []string{"<b>abc</b>", "next"}},
+		{"abc<b>defgh</b>\nnext", 12, []string{"abc<b>de</b>", "next"}},
+		{"x\n<pre>abcdefghij\nk</pre>", 14, []string{"x", "<pre>abc</pre>", "<pre>k</pre>"}},
+	}
+	for _, c := range cases {
+		if got := splitMessage(c.text, c.limit); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitMessage(%q, %v) = %q, want %q", c.text, c.limit, got, c.want)
+		}
+	}
+}
+
+func TestSplitMessageKeepsMarkupValid(t *testing.T) {
+	var lines []string
+	for i := 0; i < 300; i++ {
+		lines = append(lines, "<b>Target</b> <a href=\"http://example.com/\">example.com</a> &amp; <i>more</i>")
+	}
+	text := "<pre>" + strings.Join(lines, "\n") + "</pre>"
+	parts := splitMessage(text, maxMessageLength)
+	if len(parts) < 2 {
+		t.Fatalf("Message was split into %v parts", len(parts))
+	}
+	for i, part := range parts {
+		if length := messageLength(part); length > maxMessageLength {
+			t.Errorf("Part %v is %v long", i, length)
+		}
+		if err := checkTelegramHTML(part); err != nil {
+			t.Errorf("Part %v: %v", i, err)
+		}
+	}
+}
@@ -2,13 +2,14 @@ package telegrambot
 
 import (
 	"fmt"
-	"net/http"
-	"net/url"
+	"html/template"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/yamnikov-oleg/avamon-bot/frontend/notify"
 	"github.com/yamnikov-oleg/avamon-bot/monitor"
 
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
@@ -22,6 +23,7 @@ var (
 )
 
 func replaceHTML(input string) string {
+	input = strings.Replace(input, "&", "&amp;", -1)
 	input = strings.Replace(input, "<", "&lt;", -1)
 	input = strings.Replace(input, ">", "&gt;", -1)
 	return input
@@ -32,40 +34,91 @@ type Bot struct {
 	DB            *TargetsDB
 	TgBot         *tgbotapi.BotAPI
 	Monitor       *monitor.Monitor
-	sessionMap    map[int64]*session
+	// Dialogs idle for longer than this are canceled. Defaults to 10 minutes.
+	SessionTimeout time.Duration
+	// Telegram IDs of users allowed to manage targets and settings of any chat.
+	Superusers []int
+	// How long deleted targets can be restored with /restore. Defaults to 7 days.
+	RestoreRetention time.Duration
+	// How long the polling history is kept. Defaults to 30 days.
+	HistoryRetention time.Duration
+	// Minimum interval between two /check commands in a chat. Defaults to
+	// 30 seconds.
+	CheckInterval time.Duration
+	// Settings of chats, which have never changed them with /settings.
+	DefaultSettings ChatSettings
+	// Notifiers receiving events of all targets, e.g. globally configured
+	// webhooks.
+	Notifiers []notify.Notifier
+	// Notifiers, which routing rules refer to by names. They must also be
+	// in Notifiers.
+	NamedNotifiers map[string]notify.Notifier
+	// Whether notifiers and chats' channels receive first OK statuses of
+	// targets, e.g. after a restart of the bot. Chats decide it for
+	// themselves, see ChatSettings.NotifyFirstOK.
+	NotifyFirstOK bool
+	// Retry policy of chats' notification channels. Default to
+	// notify.DefaultRetries and notify.DefaultBackoff.
+	NotifyRetries int
+	NotifyBackoff time.Duration
+	// SMTP server for email channels, which can't be added if it's nil.
+	SMTPServer *notify.SMTPServer
+	// Domains of addresses, which chats can add email channels for. Only
+	// superusers can add other addresses.
+	EmailDomains []string
+	// Account for matrix channels, which can't be added if the homeserver
+	// is empty.
+	MatrixHomeserver string
+	MatrixToken      string
+	// Events API endpoint of pager channels. Defaults to
+	// notify.PagerDutyEventsURL.
+	PagerURL string
+	// How long email channels collect events into one email. Defaults to
+	// notify.DefaultBatchDelay.
+	EmailBatchDelay  time.Duration
+	sessions         *sessionStore
+	checkLimiter     checkLimiter
+	checkBlocklist   *hostBlocklist
+	channelNotifiers channelNotifierCache
+	templates        templateCache
+	deliveries       notify.Queue
+	// Template of notifications of chats and targets without their own
+	// templates, see SetNotificationTemplate.
+	template *template.Template
+	// See SetRoutingRules.
+	routingRules []routingRule
+	// If true, status updates matching no routing rule are dropped instead of
+	// being delivered as if there were no rules.
+	DropUnrouted bool
+	// Languages detected by detectLanguage by chat IDs.
+	languages   map[int64]string
+	languagesMu sync.Mutex
+	initOnce    sync.Once
 }
 
-func (b *Bot) formatStatusUpdate(target monitor.Target, status monitor.Status) string {
-	var output string
-	var sign string
-
-	if status.Type == monitor.StatusOK {
-		sign = strings.Repeat(okStatusEmoji, 10) + "\n"
-	} else {
-		sign = strings.Repeat(errorStatusEmoji, 10) + "\n"
-	}
-
-	output += sign
-	output += fmt.Sprintf("<b>%v:</b> <b>%v</b>\n\n", replaceHTML(target.Title), status.Type)
-	output += fmt.Sprintf("<b>URL:</b> %v\n", replaceHTML(target.URL))
-	output += fmt.Sprintf("<b>Response time:</b> %v\n", status.ResponseTime)
-
-	if status.Type != monitor.StatusOK {
-		output += fmt.Sprintf("<b>Error msg:</b> %v\n", replaceHTML(status.Err.Error()))
-	}
-	if status.Type == monitor.StatusHTTPError {
-		output += fmt.Sprintf("<b>HTTP Status:</b> %v %v\n", status.HTTPStatusCode, http.StatusText(status.HTTPStatusCode))
+func statusEmoji(st monitor.StatusType) string {
+	if st == monitor.StatusOK {
+		return okStatusEmoji
 	}
-	output += sign
-
-	return output
+	return errorStatusEmoji
 }
 
 func (b *Bot) SendMessage(chatID int64, message string) {
-	msg := tgbotapi.NewMessage(chatID, message)
-	msg.ParseMode = tgbotapi.ModeHTML
-	msg.DisableWebPagePreview = true
-	b.TgBot.Send(msg)
+	b.SendNotification(chatID, message, false)
+}
+
+// SendNotification sends the message, optionally with disabled notification
+// sound. Messages longer than Telegram allows are split in several.
+func (b *Bot) SendNotification(chatID int64, message string, silent bool) {
+	for _, part := range splitMessage(message, maxMessageLength) {
+		msg := tgbotapi.NewMessage(chatID, part)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.DisableWebPagePreview = true
+		msg.DisableNotification = silent
+		if _, err := b.TgBot.Send(msg); err != nil {
+			fmt.Printf("Could not send message to chat %v: %v\n", chatID, err)
+		}
+	}
 }
 
 func (b *Bot) SendDialogMessage(replyTo *tgbotapi.Message, message string) {
@@ -77,20 +130,16 @@ func (b *Bot) SendDialogMessage(replyTo *tgbotapi.Message, message string) {
 	}
 	msg.ParseMode = tgbotapi.ModeHTML
 	msg.DisableWebPagePreview = true
-	b.TgBot.Send(msg)
+	if _, err := b.TgBot.Send(msg); err != nil {
+		fmt.Printf("Could not send message to chat %v: %v\n", replyTo.Chat.ID, err)
+	}
 }
 
 func (b *Bot) MonitorStart() {
+	b.Init()
 	go func() {
 		for upd := range b.Monitor.Updates {
-			rec, err := b.DB.GetTarget(int(upd.Target.ID))
-			if err != nil {
-				fmt.Println(err)
-				continue
-			}
-			b.SendMessage(
-				rec.ChatID,
-				b.formatStatusUpdate(upd.Target, upd.Status))
+			b.notify(upd)
 		}
 	}()
 
@@ -103,55 +152,215 @@ func (b *Bot) MonitorStart() {
 	go b.Monitor.Run(nil)
 }
 
-type session struct {
-	Stage  int
-	Dialog dialog
+// notify sends the status update to the chats and notifiers chosen by
+// the routing rules, by default to the target's chat and to the chats
+// subscribed to it.
+func (b *Bot) notify(upd monitor.StatusUpdate) {
+	rec, err := b.DB.GetTarget(int(upd.Target.ID))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	now := time.Now()
+	inc, err := b.trackIncident(rec.ID, upd, now)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	r := b.routeUpdate(rec, upd.Status.Type, now)
+	for _, chatID := range r.Chats {
+		b.notifyChat(chatID, rec, upd, inc)
+	}
+	if upd.Status.Type == monitor.StatusOK && !upd.PrevOK && !b.NotifyFirstOK {
+		return
+	}
+	b.dispatchEvent(newEvent(upd, inc, now), r.Chats, r.notifiers(b))
+}
+
+// notifyChat sends the status update to the chat according to the chat's
+// settings.
+func (b *Bot) notifyChat(chatID int64, rec *Record, upd monitor.StatusUpdate, inc *Incident) {
+	settings, err := b.getChatSettings(chatID)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	isOK := upd.Status.Type == monitor.StatusOK
+	if isOK && !upd.PrevOK && !settings.NotifyFirstOK {
+		return
+	}
+	isRecovery := isOK && upd.PrevOK
+	if isRecovery && settings.MuteRecovery {
+		return
+	}
+
+	silent := isRecovery && settings.SilentRecovery
+	if !rec.Critical && settings.isQuietTime(time.Now()) {
+		if settings.QuietMode == QuietModeQueue {
+			if err := b.queueNotification(chatID, rec, upd); err != nil {
+				fmt.Println(err)
+			}
+			return
+		}
+		silent = true
+	}
+
+	b.SendNotification(
+		chatID,
+		b.formatStatusUpdate(rec, upd, inc, settings, time.Now()),
+		silent)
 }
 
 type dialog interface {
 	ContinueDialog(stepNumber int, update tgbotapi.Update, bot *tgbotapi.BotAPI) (int, bool)
+	// Kind returns the key of the dialog in dialogKinds.
+	Kind() string
 }
 
 type addNewTarget struct {
 	Title string
 	URL   string
 	bot   *Bot
+	// Whether the test poll is running, see startPoll.
+	polling bool
 }
 
+func (t *addNewTarget) Kind() string { return "add" }
+
 func (t *addNewTarget) ContinueDialog(stepNumber int, update tgbotapi.Update, bot *tgbotapi.BotAPI) (int, bool) {
 	if stepNumber == 1 {
 		t.bot.SendDialogMessage(
 			update.Message,
-			"Enter the title for the target. Send /cancel if you've changed your mind.")
+			t.bot.tr(update.Message.Chat.ID, "Enter the title for the target. Send /cancel if you've changed your mind."))
 		return 2, true
 	}
 	if stepNumber == 2 {
 		t.Title = update.Message.Text
-		t.bot.SendDialogMessage(update.Message, "Enter the url for the target")
+		t.bot.SendDialogMessage(update.Message, t.bot.tr(update.Message.Chat.ID, "Enter the url for the target"))
 		return 3, true
 	}
 	if stepNumber == 3 {
-		if _, err := url.Parse(update.Message.Text); err != nil {
-			t.bot.SendDialogMessage(update.Message, "Error while parsing url, please try again")
+		normalized, err := normalizeURL(update.Message.Text)
+		if err != nil {
+			t.bot.SendDialogMessage(
+				update.Message,
+				t.bot.tr(update.Message.Chat.ID, "Invalid url: %v. Please try again", replaceHTML(err.Error())))
 			return 3, true
 		}
-		t.URL = update.Message.Text
-		err := t.bot.DB.CreateTarget(Record{
-			ChatID: update.Message.Chat.ID,
-			Title:  t.Title,
-			URL:    t.URL,
-		})
+		existing, err := t.bot.findTargetByURL(update.Message.Chat.ID, normalized)
 		if err != nil {
 			t.bot.SendMessage(
 				update.Message.Chat.ID,
-				fmt.Sprintf(
-					"Error while adding the target, please contact the administrator: %v",
+				t.bot.tr(
+					update.Message.Chat.ID,
+					"Error while retrieving the targets, please contact the administrator: %v",
 					t.bot.AdminNickname))
 			return 0, false
 		}
-		t.bot.SendMessage(update.Message.Chat.ID, "Target was successfully added")
+		if existing != nil {
+			t.bot.SendDialogMessage(
+				update.Message,
+				t.bot.tr(
+					update.Message.Chat.ID,
+					"This url is already monitored as <b>%v</b> (ID %v). Please enter another url",
+					replaceHTML(existing.Title), existing.ID))
+			return 3, true
+		}
+		t.URL = normalized
+		t.startPoll(update.Message)
+		return 5, true
+	}
+	if stepNumber == 4 {
+		answer := strings.ToLower(strings.TrimSpace(update.Message.Text))
+		if answer == "yes" || answer == t.bot.tr(update.Message.Chat.ID, "yes") {
+			return t.save(update.Message)
+		}
+		return t.ContinueDialog(3, update, bot)
+	}
+	if stepNumber == 5 {
+		// The poll is lost if the bot was restarted while it was running.
+		if !t.polling {
+			t.startPoll(update.Message)
+		}
+		t.bot.SendDialogMessage(
+			update.Message,
+			t.bot.tr(
+				update.Message.Chat.ID,
+				"Test poll of %v is in progress, please wait or send /cancel", replaceHTML(t.URL)))
+		return 5, true
+	}
+	return 0, false
+}
+
+// startPoll polls the target's URL in background, so that a slow site
+// doesn't hold up other chats, and continues the dialog with the result
+// unless it has been canceled meanwhile.
+func (t *addNewTarget) startPoll(message *tgbotapi.Message) {
+	t.polling = true
+	key := sessionKeyOf(message)
+	url := t.URL
+	go func() {
+		status := t.bot.pollURL(message.Chat.ID, url)
+
+		sess := t.bot.sessions.Acquire(key)
+		defer t.bot.sessions.Release(key, sess)
+		if sess.Dialog != dialog(t) || sess.Stage != 5 {
+			return
+		}
+		t.polling = false
+		var ok bool
+		sess.Stage, ok = t.finishPoll(message, status)
+		if !ok {
+			sess.Dialog = nil
+		}
+	}()
+}
+
+func (t *addNewTarget) finishPoll(message *tgbotapi.Message, status monitor.Status) (int, bool) {
+	if status.Type != monitor.StatusOK {
+		t.bot.SendDialogMessage(
+			message,
+			t.bot.tr(
+				message.Chat.ID,
+				"%v Test poll of %v failed: <b>%v</b> (%v)\n\n"+
+					"Send <b>yes</b> to add the target anyway, or enter another url.",
+				statusEmoji(status.Type), replaceHTML(t.URL),
+				t.bot.tr(message.Chat.ID, status.Type.String()),
+				replaceHTML(status.Err.Error())))
+		return 4, true
+	}
+
+	t.bot.SendMessage(
+		message.Chat.ID,
+		t.bot.tr(
+			message.Chat.ID,
+			"%v Test poll of %v: <b>%v</b> (%v ms)",
+			statusEmoji(status.Type), replaceHTML(t.URL),
+			t.bot.tr(message.Chat.ID, status.Type.String()),
+			int64(status.ResponseTime/time.Millisecond)))
+	return t.save(message)
+}
+
+func (t *addNewTarget) save(message *tgbotapi.Message) (int, bool) {
+	record := Record{
+		ChatID: message.Chat.ID,
+		Title:  t.Title,
+		URL:    t.URL,
+	}
+	err := t.bot.DB.CreateTarget(&record)
+	if err != nil {
+		t.bot.SendMessage(
+			message.Chat.ID,
+			t.bot.tr(
+				message.Chat.ID,
+				"Error while adding the target, please contact the administrator: %v",
+				t.bot.AdminNickname))
 		return 0, false
 	}
+	t.bot.audit(message, ActionCreate, nil, &record)
+	t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "Target was successfully added"))
 	return 0, false
 }
 
@@ -159,59 +368,289 @@ type deleteTarget struct {
 	bot *Bot
 }
 
+func (t *deleteTarget) Kind() string { return "delete" }
+
+// sendTargetChoice lists the chat's targets in a dialog message, prefixed with
+// the prompt. It returns false if there is nothing to choose from.
+func (b *Bot) sendTargetChoice(message *tgbotapi.Message, prompt string) bool {
+	targs, err := b.DB.GetCurrentTargets(message.Chat.ID)
+	if err != nil {
+		b.SendMessage(
+			message.Chat.ID,
+			b.tr(
+				message.Chat.ID,
+				"Error while retrieving the targets, please contact the administrator: %v",
+				b.AdminNickname))
+		return false
+	}
+	if len(targs) == 0 {
+		b.SendMessage(message.Chat.ID, b.tr(message.Chat.ID, "You have no targets added! Use /add to add one"))
+		return false
+	}
+	var targetStrings []string
+	targetStrings = append(targetStrings, prompt+"\n")
+	for _, target := range targs {
+		line := fmt.Sprintf(
+			"<b>%v</b>: <a href=\"%v\">%v</a>",
+			target.ID,
+			replaceHTML(target.URL),
+			replaceHTML(target.Title),
+		)
+		if tags := target.TagList(); len(tags) > 0 {
+			line += " " + formatTags(tags)
+		}
+		targetStrings = append(targetStrings, line)
+	}
+	b.SendDialogMessage(message, strings.Join(targetStrings, "\n"))
+	return true
+}
+
 func (t *deleteTarget) ContinueDialog(stepNumber int, update tgbotapi.Update, bot *tgbotapi.BotAPI) (int, bool) {
 	if stepNumber == 1 {
-		targs, err := t.bot.DB.GetCurrentTargets(update.Message.Chat.ID)
-		if err != nil {
-			t.bot.SendMessage(
+		ok := t.bot.sendTargetChoice(
+			update.Message,
+			t.bot.tr(
 				update.Message.Chat.ID,
-				fmt.Sprintf(
-					"Error while retrieving the targets, please contact the administrator: %v",
-					t.bot.AdminNickname))
-			return 0, false
-		}
-		if len(targs) == 0 {
-			t.bot.SendMessage(update.Message.Chat.ID, "You have no targets added! Use /add to add one")
+				"Enter the <b>ID</b> of a target to delete it. Send /cancel if you've changed your mind."))
+		if !ok {
 			return 0, false
 		}
-		var targetStrings []string
-		targetStrings = append(targetStrings, "Enter the <b>ID</b> of a target to delete it. Send /cancel if you've changed your mind.\n")
-		for _, target := range targs {
-			targetStrings = append(
-				targetStrings,
-				fmt.Sprintf(
-					"<b>%v</b>: <a href=\"%v\">%v</a>",
-					target.ID,
-					replaceHTML(target.URL),
-					replaceHTML(target.Title),
-				),
-			)
-		}
-		message := strings.Join(targetStrings, "\n")
-		t.bot.SendDialogMessage(update.Message, message)
 		return 2, true
 	}
 	if stepNumber == 2 {
 		target, err := strconv.Atoi(update.Message.Text)
 		if err != nil {
-			t.bot.SendDialogMessage(update.Message, "Invalid ID, please try again")
+			t.bot.SendDialogMessage(update.Message, t.bot.tr(update.Message.Chat.ID, "Invalid ID, please try again"))
 			return 2, true
 		}
 		targetFromDB, err := t.bot.DB.GetTarget(target)
 		if err != nil || targetFromDB.ChatID != update.Message.Chat.ID {
-			t.bot.SendMessage(update.Message.Chat.ID, "No target with such ID found")
+			t.bot.SendMessage(update.Message.Chat.ID, t.bot.tr(update.Message.Chat.ID, "No target with such ID found"))
 			return 0, false
 		}
 		err = t.bot.DB.DeleteTarget(target)
 		if err != nil {
 			t.bot.SendMessage(
 				update.Message.Chat.ID,
-				fmt.Sprintf(
+				t.bot.tr(
+					update.Message.Chat.ID,
 					"Error while deleting the target, please contact the administrator: %v",
 					t.bot.AdminNickname))
 			return 0, false
 		}
-		t.bot.SendMessage(update.Message.Chat.ID, "Target was successfully deleted!")
+		t.bot.audit(update.Message, ActionDelete, targetFromDB, nil)
+		t.bot.notifySubscribersOfDeletion(targetFromDB)
+		t.bot.SendMessage(update.Message.Chat.ID, t.bot.tr(update.Message.Chat.ID, "Target was successfully deleted!"))
+		return 0, false
+	}
+	return 0, false
+}
+
+type editTarget struct {
+	TargetID uint
+	Title    string
+	bot      *Bot
+}
+
+func (t *editTarget) Kind() string { return "edit" }
+
+func (t *editTarget) ContinueDialog(stepNumber int, update tgbotapi.Update, bot *tgbotapi.BotAPI) (int, bool) {
+	if stepNumber == 1 {
+		ok := t.bot.sendTargetChoice(
+			update.Message,
+			t.bot.tr(
+				update.Message.Chat.ID,
+				"Enter the <b>ID</b> of a target to edit it. Send /cancel if you've changed your mind."))
+		if !ok {
+			return 0, false
+		}
+		return 2, true
+	}
+	if stepNumber == 2 {
+		id, err := strconv.Atoi(update.Message.Text)
+		if err != nil {
+			t.bot.SendDialogMessage(update.Message, t.bot.tr(update.Message.Chat.ID, "Invalid ID, please try again"))
+			return 2, true
+		}
+		target, err := t.bot.DB.GetTarget(id)
+		if err != nil || target.ChatID != update.Message.Chat.ID {
+			t.bot.SendMessage(update.Message.Chat.ID, t.bot.tr(update.Message.Chat.ID, "No target with such ID found"))
+			return 0, false
+		}
+		t.TargetID = target.ID
+		t.bot.SendDialogMessage(
+			update.Message,
+			t.bot.tr(
+				update.Message.Chat.ID,
+				"Enter the new title for the target or send <b>-</b> to keep <i>%v</i>",
+				replaceHTML(target.Title)))
+		return 3, true
+	}
+	if stepNumber == 3 {
+		t.Title = update.Message.Text
+		t.bot.SendDialogMessage(update.Message, t.bot.tr(update.Message.Chat.ID, "Enter the new url for the target or send <b>-</b> to keep the current one"))
+		return 4, true
+	}
+	if stepNumber == 4 {
+		target, err := t.bot.DB.GetTarget(int(t.TargetID))
+		if err != nil || target.ChatID != update.Message.Chat.ID {
+			t.bot.SendMessage(update.Message.Chat.ID, t.bot.tr(update.Message.Chat.ID, "No target with such ID found"))
+			return 0, false
+		}
+		before := *target
+		if t.Title != "-" {
+			target.Title = t.Title
+		}
+		if update.Message.Text != "-" {
+			normalized, err := normalizeURL(update.Message.Text)
+			if err != nil {
+				t.bot.SendDialogMessage(
+					update.Message,
+					t.bot.tr(update.Message.Chat.ID, "Invalid url: %v. Please try again", replaceHTML(err.Error())))
+				return 4, true
+			}
+			target.URL = normalized
+		}
+		err = t.bot.DB.UpdateTarget(*target)
+		if err != nil {
+			t.bot.SendMessage(
+				update.Message.Chat.ID,
+				t.bot.tr(
+					update.Message.Chat.ID,
+					"Error while editing the target, please contact the administrator: %v",
+					t.bot.AdminNickname))
+			return 0, false
+		}
+		t.bot.audit(update.Message, ActionEdit, &before, target)
+		t.bot.SendMessage(update.Message.Chat.ID, t.bot.tr(update.Message.Chat.ID, "Target was successfully edited"))
+		return 0, false
+	}
+	return 0, false
+}
+
+type pauseTarget struct {
+	// True to pause the target, false to resume it.
+	Pause bool
+	bot   *Bot
+}
+
+func (t *pauseTarget) Kind() string { return "pause" }
+
+func (t *pauseTarget) apply(message *tgbotapi.Message, text string) {
+	text = strings.TrimSpace(text)
+	id, err := strconv.Atoi(text)
+	if err != nil {
+		t.applyToTag(message, text)
+		return
+	}
+	target, err := t.bot.DB.GetTarget(id)
+	if err != nil || target.ChatID != message.Chat.ID {
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "No target with such ID found"))
+		return
+	}
+
+	before := *target
+	target.Paused = t.Pause
+	err = t.bot.DB.UpdateTarget(*target)
+	if err != nil {
+		t.bot.SendMessage(
+			message.Chat.ID,
+			t.bot.tr(
+				message.Chat.ID,
+				"Error while editing the target, please contact the administrator: %v",
+				t.bot.AdminNickname))
+		return
+	}
+
+	if t.Pause {
+		t.bot.audit(message, ActionPause, &before, target)
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "Target was paused, use /resume to continue monitoring it"))
+	} else {
+		t.bot.audit(message, ActionResume, &before, target)
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "Target was resumed"))
+	}
+}
+
+// applyToTag pauses or resumes all targets with the tag, e.g. for
+// a maintenance.
+func (t *pauseTarget) applyToTag(message *tgbotapi.Message, text string) {
+	tags, err := parseTags(text)
+	if err != nil || len(tags) != 1 {
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "Invalid ID or tag"))
+		return
+	}
+	targs, err := t.bot.getTargetsByTag(message.Chat.ID, tags[0])
+	if err != nil {
+		t.bot.SendMessage(
+			message.Chat.ID,
+			t.bot.tr(
+				message.Chat.ID,
+				"Error while retrieving the targets, please contact the administrator: %v",
+				t.bot.AdminNickname))
+		return
+	}
+	if len(targs) == 0 {
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(message.Chat.ID, "No targets with this tag"))
+		return
+	}
+
+	action := ActionResume
+	if t.Pause {
+		action = ActionPause
+	}
+	for i := range targs {
+		if targs[i].Paused == t.Pause {
+			continue
+		}
+		before := targs[i]
+		targs[i].Paused = t.Pause
+		if err := t.bot.DB.UpdateTarget(targs[i]); err != nil {
+			t.bot.SendMessage(
+				message.Chat.ID,
+				t.bot.tr(
+					message.Chat.ID,
+					"Error while editing the target, please contact the administrator: %v",
+					t.bot.AdminNickname))
+			return
+		}
+		t.bot.audit(message, action, &before, &targs[i])
+	}
+
+	if t.Pause {
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(
+			message.Chat.ID,
+			"%v targets with tag %v were paused, use /resume %v to continue monitoring them",
+			len(targs), formatTags(tags), tags[0]))
+	} else {
+		t.bot.SendMessage(message.Chat.ID, t.bot.tr(
+			message.Chat.ID,
+			"%v targets with tag %v were resumed", len(targs), formatTags(tags)))
+	}
+}
+
+func (t *pauseTarget) ContinueDialog(stepNumber int, update tgbotapi.Update, bot *tgbotapi.BotAPI) (int, bool) {
+	if stepNumber == 1 {
+		if args := update.Message.CommandArguments(); args != "" {
+			t.apply(update.Message, args)
+			return 0, false
+		}
+		prompt := t.bot.tr(
+			update.Message.Chat.ID,
+			"Enter the <b>ID</b> of a target or a tag to resume the targets. "+
+				"Send /cancel if you've changed your mind.")
+		if t.Pause {
+			prompt = t.bot.tr(
+				update.Message.Chat.ID,
+				"Enter the <b>ID</b> of a target or a tag to pause the targets. "+
+					"Send /cancel if you've changed your mind.")
+		}
+		if !t.bot.sendTargetChoice(update.Message, prompt) {
+			return 0, false
+		}
+		return 2, true
+	}
+	if stepNumber == 2 {
+		t.apply(update.Message, update.Message.Text)
 		return 0, false
 	}
 	return 0, false
@@ -221,19 +660,18 @@ func (b *Bot) Dispatch(update *tgbotapi.Update) {
 	if update.Message == nil {
 		return
 	}
-	if _, ok := b.sessionMap[update.Message.Chat.ID]; !ok {
-		b.sessionMap[update.Message.Chat.ID] = &session{}
-		b.sessionMap[update.Message.Chat.ID].Stage = 1
-		b.sessionMap[update.Message.Chat.ID].Dialog = nil
-	}
-	sess := b.sessionMap[update.Message.Chat.ID]
+	b.detectLanguage(update.Message)
+	key := sessionKeyOf(update.Message)
+	sess := b.sessions.Acquire(key)
+	defer b.sessions.Release(key, sess)
+
 	if update.Message.Command() == "cancel" {
 		if sess.Dialog != nil {
 			sess.Dialog = nil
 			sess.Stage = 0
-			b.SendMessage(update.Message.Chat.ID, "Action has been canceled")
+			b.SendMessage(update.Message.Chat.ID, b.tr(update.Message.Chat.ID, "Action has been canceled"))
 		} else {
-			b.SendMessage(update.Message.Chat.ID, "No action in process")
+			b.SendMessage(update.Message.Chat.ID, b.tr(update.Message.Chat.ID, "No action in process"))
 		}
 		return
 	}
@@ -246,89 +684,209 @@ func (b *Bot) Dispatch(update *tgbotapi.Update) {
 		return
 	}
 	if update.Message.Command() == "start" {
+		if token := strings.TrimSpace(update.Message.CommandArguments()); token != "" {
+			b.subscribeByToken(update.Message, token)
+			return
+		}
 		b.SendMessage(
 			update.Message.Chat.ID,
-			"Hi!\nI'm a bot which can monitor sites' availability and notify you when a site goes down or up again.\n")
+			b.tr(update.Message.Chat.ID, "Hi!\nI'm a bot which can monitor sites' availability and notify you when a site goes down or up again.\n"))
 		return
 	}
 	if update.Message.Command() == "add" {
-		b.StartDialog(update, &addNewTarget{
+		if !b.checkPermission(update.Message, b.canManageTargets) {
+			return
+		}
+		b.StartDialog(sess, update, &addNewTarget{
 			bot: b,
 		})
 	}
 	if update.Message.Command() == "targets" {
-		targs, err := b.DB.GetCurrentTargets(update.Message.Chat.ID)
-		if err != nil {
-			b.SendMessage(
-				update.Message.Chat.ID,
-				fmt.Sprintf(
-					"Error while retrieving the targets, please contact the administrator: %v",
-					b.AdminNickname))
+		b.sendTargetList(update.Message)
+		return
+	}
+	if update.Message.Command() == "delete" {
+		if !b.checkPermission(update.Message, b.canManageTargets) {
 			return
 		}
-		if len(targs) == 0 {
-			b.SendMessage(update.Message.Chat.ID, "No targets! Use /add to add one.")
+		b.StartDialog(sess, update, &deleteTarget{
+			bot: b,
+		})
+	}
+	if update.Message.Command() == "edit" {
+		if !b.checkPermission(update.Message, b.canManageTargets) {
 			return
 		}
-		var targetStrings []string
-		for _, target := range targs {
-			status, ok, err := b.Monitor.StatusStore.GetStatus(target.ToTarget())
-			if err != nil {
-				b.SendMessage(
-					update.Message.Chat.ID,
-					fmt.Sprintf(
-						"Error while retrieving the target's status, please contact the administrator: %v",
-						b.AdminNickname))
-				continue
-			}
-
-			var header string
-			header = fmt.Sprintf(
-				"<a href=\"%v\">%v</a>",
-				replaceHTML(target.URL), replaceHTML(target.Title))
-
-			var statusText string
-			if ok {
-				var emoji string
-				if status.Type == monitor.StatusOK {
-					emoji = okStatusEmoji
-				} else {
-					emoji = errorStatusEmoji
-				}
-
-				statusText = fmt.Sprintf(
-					"%v %v (%v ms)",
-					emoji, status.Type, int64(status.ResponseTime/time.Millisecond))
-			} else {
-				statusText = "N/A"
-			}
-
-			targetStrings = append(
-				targetStrings, fmt.Sprintf("%v: %v", header, statusText))
+		b.StartDialog(sess, update, &editTarget{
+			bot: b,
+		})
+	}
+	if update.Message.Command() == "pause" || update.Message.Command() == "resume" {
+		if !b.checkPermission(update.Message, b.canManageTargets) {
+			return
+		}
+		b.StartDialog(sess, update, &pauseTarget{
+			Pause: update.Message.Command() == "pause",
+			bot:   b,
+		})
+	}
+	if update.Message.Command() == "critical" {
+		if !b.checkPermission(update.Message, b.canManageTargets) {
+			return
+		}
+		b.StartDialog(sess, update, &markCritical{
+			bot: b,
+		})
+	}
+	if update.Message.Command() == "restore" {
+		if !b.checkPermission(update.Message, b.canManageTargets) {
+			return
+		}
+		b.StartDialog(sess, update, &restoreTarget{
+			bot: b,
+		})
+	}
+	if update.Message.Command() == "tag" || update.Message.Command() == "untag" {
+		if !b.checkPermission(update.Message, b.canManageTargets) {
+			return
 		}
-		message := strings.Join(targetStrings, "\n")
-		b.SendMessage(update.Message.Chat.ID, message)
+		b.changeTags(update.Message, update.Message.Command() == "tag")
 		return
 	}
-	if update.Message.Command() == "delete" {
-		b.StartDialog(update, &deleteTarget{
+	if update.Message.Command() == "share" || update.Message.Command() == "unshare" {
+		if !b.checkPermission(update.Message, b.canManageTargets) {
+			return
+		}
+		if update.Message.Command() == "share" {
+			b.shareTarget(update.Message)
+		} else {
+			b.unshareTarget(update.Message)
+		}
+		return
+	}
+	if update.Message.Command() == "unsubscribe" {
+		if !b.checkPermission(update.Message, b.canManageTargets) {
+			return
+		}
+		b.unsubscribe(update.Message)
+		return
+	}
+	if update.Message.Command() == "channels" {
+		b.manageChannels(update.Message)
+		return
+	}
+	if update.Message.Command() == "import" {
+		if !b.checkPermission(update.Message, b.canManageTargets) {
+			return
+		}
+		b.StartDialog(sess, update, &importTargets{
+			bot: b,
+		})
+	}
+	if update.Message.Command() == "export" {
+		b.sendExport(update.Message)
+		return
+	}
+	if update.Message.Command() == "report" {
+		b.sendReport(update.Message.Chat.ID, update.Message.CommandArguments())
+		return
+	}
+	if update.Message.Command() == "check" {
+		go b.runCheck(update.Message)
+		return
+	}
+	if update.Message.Command() == "graph" {
+		b.sendGraph(update.Message)
+		return
+	}
+	if update.Message.Command() == "template" {
+		b.manageTemplates(update.Message)
+		return
+	}
+	if update.Message.Command() == "preview" {
+		b.sendPreview(update.Message)
+		return
+	}
+	if update.Message.Command() == "route" {
+		b.sendRoute(update.Message)
+		return
+	}
+	if update.Message.Command() == "hooks" {
+		b.sendHookRuns(update.Message)
+		return
+	}
+	if update.Message.Command() == "audit" {
+		b.sendAuditLog(update.Message)
+		return
+	}
+	if update.Message.Command() == "settings" {
+		if !b.checkPermission(update.Message, b.canChangeSettings) {
+			return
+		}
+		b.StartDialog(sess, update, &changeSettings{
 			bot: b,
 		})
 	}
 }
 
-func (b *Bot) StartDialog(update *tgbotapi.Update, dialog dialog) {
+func (b *Bot) StartDialog(sess *session, update *tgbotapi.Update, dialog dialog) {
 	var ok bool
-	b.sessionMap[update.Message.Chat.ID].Dialog = dialog
-	b.sessionMap[update.Message.Chat.ID].Stage, ok = dialog.ContinueDialog(1, *update, b.TgBot)
+	sess.Dialog = dialog
+	sess.Stage, ok = dialog.ContinueDialog(1, *update, b.TgBot)
 	if !ok {
-		b.sessionMap[update.Message.Chat.ID].Dialog = nil
+		sess.Dialog = nil
 	}
 	return
 }
 
+func (b *Bot) expireSessions() {
+	for range time.Tick(time.Minute) {
+		for _, key := range b.sessions.Expire(b.SessionTimeout) {
+			b.SendMessage(key.ChatID, b.tr(key.ChatID, "Action has been canceled due to inactivity"))
+		}
+	}
+}
+
+// Init sets defaults of the fields, which haven't been configured. It must be
+// called after configuring the bot and before MonitorStart and Run, which
+// call it too in case it wasn't. Later calls do nothing.
+func (b *Bot) Init() {
+	b.initOnce.Do(func() {
+		if b.SessionTimeout == 0 {
+			b.SessionTimeout = 10 * time.Minute
+		}
+		if b.RestoreRetention == 0 {
+			b.RestoreRetention = 7 * 24 * time.Hour
+		}
+		if b.HistoryRetention == 0 {
+			b.HistoryRetention = 30 * 24 * time.Hour
+		}
+		if b.CheckInterval == 0 {
+			b.CheckInterval = 30 * time.Second
+		}
+		if b.NotifyRetries == 0 {
+			b.NotifyRetries = notify.DefaultRetries
+		}
+		if b.NotifyBackoff == 0 {
+			b.NotifyBackoff = notify.DefaultBackoff
+		}
+		if b.EmailBatchDelay == 0 {
+			b.EmailBatchDelay = notify.DefaultBatchDelay
+		}
+	})
+}
+
 func (b *Bot) Run() error {
-	b.sessionMap = map[int64]*session{}
+	b.Init()
+	b.sessions = newSessionStore(b)
+	if err := b.sessions.Load(b.SessionTimeout); err != nil {
+		return err
+	}
+	go b.expireSessions()
+	go b.purgeDeletedTargets()
+	go b.deliverDigests()
+	go b.purgePollHistory()
+	go b.deliverReports()
 
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 0
@@ -0,0 +1,537 @@
+package telegrambot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/yamnikov-oleg/avamon-bot/monitor"
+)
+
+// templateStatus is a status as seen by notification templates.
+type templateStatus struct {
+	// E.g. "OK", "Timeout" or "HTTP Error".
+	Type           string
+	OK             bool
+	Error          string
+	ResponseTime   time.Duration
+	HTTPStatusCode int
+	HTTPStatusText string
+}
+
+func newTemplateStatus(st monitor.Status) *templateStatus {
+	ts := &templateStatus{
+		Type:           st.Type.String(),
+		OK:             st.Type == monitor.StatusOK,
+		ResponseTime:   st.ResponseTime,
+		HTTPStatusCode: st.HTTPStatusCode,
+		HTTPStatusText: http.StatusText(st.HTTPStatusCode),
+	}
+	if st.Err != nil {
+		ts.Error = st.Err.Error()
+	}
+	return ts
+}
+
+// templateTarget is a target as seen by notification templates.
+type templateTarget struct {
+	ID       uint
+	Title    string
+	URL      string
+	Tags     []string
+	Critical bool
+}
+
+// templateData is what notification templates are executed with.
+type templateData struct {
+	Target templateTarget
+	Status *templateStatus
+	// Nil if the target had no status before.
+	PrevStatus *templateStatus
+	// The emoji of the status.
+	Emoji string
+	// When the status changed, in the chat's time zone.
+	Time time.Time
+	// When the incident started and how long it has lasted, zero if
+	// the status change doesn't belong to an incident.
+	IncidentStart time.Time
+	Duration      time.Duration
+	// Whether the target is OK after a failure.
+	Recovery bool
+	// The chat's settings: one of Verbosity* constants and whether details
+	// should be hidden.
+	Verbosity   string
+	HideDetails bool
+	// The chat's language, one of Language* constants.
+	Language string
+}
+
+// T translates the message into the chat's language, e.g.
+// {{.T "Response time"}}.
+func (d templateData) T(message string) string {
+	return translate(d.Language, message)
+}
+
+// FormatTime formats the time as customary in the chat's language.
+func (d templateData) FormatTime(t time.Time) string {
+	return formatTime(d.Language, t, "2006-01-02 15:04:05 MST")
+}
+
+// FormatDuration formats the duration with units of the chat's language.
+func (d templateData) FormatDuration(x time.Duration) string {
+	return localizeDuration(d.Language, x)
+}
+
+func newTemplateData(rec *Record, upd monitor.StatusUpdate, inc *Incident, settings ChatSettings, lang string, at time.Time) templateData {
+	loc := settings.Location()
+	data := templateData{
+		Target: templateTarget{
+			ID:       rec.ID,
+			Title:    rec.Title,
+			URL:      rec.URL,
+			Tags:     rec.TagList(),
+			Critical: rec.Critical,
+		},
+		Status:      newTemplateStatus(upd.Status),
+		Emoji:       statusEmoji(upd.Status.Type),
+		Time:        at.In(loc),
+		Recovery:    upd.Status.Type == monitor.StatusOK && upd.PrevOK,
+		Verbosity:   settings.Verbosity,
+		HideDetails: settings.HideDetails,
+		Language:    lang,
+	}
+	if data.Verbosity == "" {
+		data.Verbosity = VerbosityNormal
+	}
+	if upd.PrevOK {
+		data.PrevStatus = newTemplateStatus(upd.PrevStatus)
+	}
+	if inc != nil {
+		data.IncidentStart = inc.StartedAt.In(loc)
+		data.Duration = at.Sub(inc.StartedAt)
+	}
+	return data
+}
+
+// Templates can't repeat strings more times than this, so that a template
+// can't make the bot run out of memory.
+const maxRepeat = 100
+
+// maxTemplateOutput is how many bytes a template may write, enough for
+// a message of maxMessageLength characters of any kind.
+const maxTemplateOutput = 4 * maxMessageLength
+
+func repeat(s string, n int) (string, error) {
+	if n < 0 || n > maxRepeat {
+		return "", errorf("repeat count must be between 0 and %v", maxRepeat)
+	}
+	if len(s)*n > maxTemplateOutput {
+		return "", errorf("The message is longer than %v characters", maxMessageLength)
+	}
+	return strings.Repeat(s, n), nil
+}
+
+var templateFuncs = template.FuncMap{
+	"repeat": repeat,
+	"tags":   formatTags,
+	// Rounds durations to seconds, e.g. "1h2m3s".
+	"duration": func(d time.Duration) string { return d.Round(time.Second).String() },
+}
+
+// defaultTemplateText reproduces the notifications of the bot before
+// templates were introduced.
+const defaultTemplateText = `{{if eq .Verbosity "short" -}}
+{{.Emoji}} <b>{{.Target.Title}}</b>: {{.T .Status.Type}}
+{{- if and (not .Status.OK) (not .HideDetails)}} ({{.Status.Error}}){{end}}
+{{- if .Target.Tags}} {{tags .Target.Tags}}{{end}}
+{{- else -}}
+{{repeat .Emoji 10}}
+<b>{{.Target.Title}}:</b> <b>{{.T .Status.Type}}</b>
+
+<b>{{.T "URL"}}:</b> {{.Target.URL}}
+{{if .Target.Tags}}<b>{{.T "Tags"}}:</b> {{tags .Target.Tags}}
+{{end -}}
+<b>{{.T "Time"}}:</b> {{.FormatTime .Time}}
+{{if and (eq .Verbosity "full") .PrevStatus}}<b>{{.T "Previous status"}}:</b> {{.T .PrevStatus.Type}}
+{{end -}}
+{{if not .HideDetails}}<b>{{.T "Response time"}}:</b> {{.FormatDuration .Status.ResponseTime}}
+{{if not .Status.OK}}<b>{{.T "Error msg"}}:</b> {{.Status.Error}}
+{{end -}}
+{{if or (eq .Status.Type "HTTP Error") (and (eq .Verbosity "full") .Status.HTTPStatusCode)}}<b>{{.T "HTTP Status"}}:</b> {{.Status.HTTPStatusCode}} {{.Status.HTTPStatusText}}
+{{end -}}
+{{end -}}
+{{repeat .Emoji 10}}
+{{end}}`
+
+var defaultTemplate = template.Must(template.New("notification").Funcs(templateFuncs).Parse(defaultTemplateText))
+
+// sampleTemplateData returns data of a failure and of the following recovery
+// of the target to validate and preview templates with.
+func sampleTemplateData(rec *Record, settings ChatSettings, lang string) []templateData {
+	if rec == nil {
+		rec = &Record{ID: 1, Title: "Example", URL: "http://example.com", Tags: "prod"}
+	}
+	now := time.Now()
+	inc := &Incident{StartedAt: now.Add(-5 * time.Minute)}
+	down := monitor.StatusUpdate{
+		Target: rec.ToTarget(),
+		Status: monitor.Status{
+			Type:           monitor.StatusHTTPError,
+			ResponseTime:   230 * time.Millisecond,
+			HTTPStatusCode: 502,
+			Err:            errors.New("HTTP status 502 Bad Gateway"),
+		},
+		PrevStatus: monitor.Status{Type: monitor.StatusOK, ResponseTime: 120 * time.Millisecond, HTTPStatusCode: 200},
+		PrevOK:     true,
+	}
+	up := monitor.StatusUpdate{
+		Target:     down.Target,
+		Status:     down.PrevStatus,
+		PrevStatus: down.Status,
+		PrevOK:     true,
+	}
+	return []templateData{
+		newTemplateData(rec, down, &Incident{StartedAt: now}, settings, lang, now),
+		newTemplateData(rec, up, inc, settings, lang, now),
+	}
+}
+
+// telegramTags are HTML tags supported by Telegram.
+var telegramTags = map[string]bool{
+	"b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "a": true, "code": true, "pre": true,
+	"span": true, "tg-spoiler": true, "blockquote": true,
+}
+
+var htmlTagRegexp = regexp.MustCompile(`<(/?)([a-zA-Z-]+)[^>]*>`)
+
+// checkTelegramHTML checks that the message only uses tags supported by
+// Telegram and closes them, otherwise Telegram would reject it.
+func checkTelegramHTML(message string) error {
+	var open []string
+	for _, match := range htmlTagRegexp.FindAllStringSubmatch(message, -1) {
+		closing, name := match[1] == "/", strings.ToLower(match[2])
+		if !telegramTags[name] {
+			return errorf("Tag <%v> is not supported by Telegram", name)
+		}
+		if !closing {
+			open = append(open, name)
+			continue
+		}
+		if len(open) == 0 || open[len(open)-1] != name {
+			return errorf("Unexpected </%v>", name)
+		}
+		open = open[:len(open)-1]
+	}
+	if len(open) > 0 {
+		return errorf("Tag <%v> is not closed", open[len(open)-1])
+	}
+	return nil
+}
+
+// limitedBuffer is a buffer refusing writes beyond the limit, which stops
+// the execution of a template producing too long output.
+type limitedBuffer struct {
+	bytes.Buffer
+	limit    int
+	exceeded bool
+}
+
+func (lb *limitedBuffer) Write(p []byte) (int, error) {
+	if lb.Len()+len(p) > lb.limit {
+		lb.exceeded = true
+		return 0, errorf("The message is longer than %v characters", maxMessageLength)
+	}
+	return lb.Buffer.Write(p)
+}
+
+// executeTemplate renders the template into a message.
+func executeTemplate(tmpl *template.Template, data templateData) (string, error) {
+	buf := limitedBuffer{limit: maxTemplateOutput}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		if buf.exceeded {
+			return "", errorf("The message is longer than %v characters", maxMessageLength)
+		}
+		return "", err
+	}
+	output := strings.TrimSpace(buf.String())
+	if output == "" {
+		return "", errorf("The message is empty")
+	}
+	if messageLength(output) > maxMessageLength {
+		return "", errorf("The message is longer than %v characters", maxMessageLength)
+	}
+	if err := checkTelegramHTML(output); err != nil {
+		return "", err
+	}
+	return output, nil
+}
+
+// parseTemplate parses a notification template and checks that it renders
+// sample notifications without errors.
+func parseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("notification").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range sampleTemplateData(nil, ChatSettings{}, LanguageEnglish) {
+		if _, err := executeTemplate(tmpl, data); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// maxCachedTemplates limits templateCache, so that texts of edited templates
+// don't pile up.
+const maxCachedTemplates = 1000
+
+type parsedTemplate struct {
+	tmpl *template.Template
+	err  error
+}
+
+// templateCache keeps templates of targets and chats parsed by their texts,
+// so that they aren't parsed again for every notification.
+type templateCache struct {
+	mu        sync.Mutex
+	templates map[string]parsedTemplate
+}
+
+// get returns the parsed template, parsing it if needed.
+func (c *templateCache) get(text string) (*template.Template, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.templates[text]; ok {
+		return p.tmpl, p.err
+	}
+	tmpl, err := parseTemplate(text)
+	if c.templates == nil || len(c.templates) >= maxCachedTemplates {
+		c.templates = map[string]parsedTemplate{}
+	}
+	c.templates[text] = parsedTemplate{tmpl, err}
+	return tmpl, err
+}
+
+// SetNotificationTemplate validates and sets the template of notifications
+// of chats and targets without their own templates. An empty text means
+// the built-in layout.
+func (b *Bot) SetNotificationTemplate(text string) error {
+	if text == "" {
+		b.template = nil
+		return nil
+	}
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return errorf("Invalid notification template: %v", err)
+	}
+	b.template = tmpl
+	return nil
+}
+
+// notificationTemplate returns the template for notifications about
+// the target in the chat: the target's, the chat's, the bot's or
+// the default one, whichever is set first.
+func (b *Bot) notificationTemplate(rec *Record, settings ChatSettings) *template.Template {
+	for _, text := range []string{rec.Template, settings.Template} {
+		if text == "" {
+			continue
+		}
+		tmpl, err := b.templates.get(text)
+		if err != nil {
+			fmt.Printf("Invalid template of target %v or chat %v: %v\n", rec.ID, settings.ChatID, err)
+			continue
+		}
+		return tmpl
+	}
+	if b.template != nil {
+		return b.template
+	}
+	return defaultTemplate
+}
+
+// formatStatusUpdate renders the notification about the status update with
+// the template of the target and the chat. If the template fails, the default
+// one is used.
+func (b *Bot) formatStatusUpdate(rec *Record, upd monitor.StatusUpdate, inc *Incident, settings ChatSettings, at time.Time) string {
+	data := newTemplateData(rec, upd, inc, settings, b.languageOf(settings), at)
+	output, err := executeTemplate(b.notificationTemplate(rec, settings), data)
+	if err != nil {
+		fmt.Printf("Could not render notification of target %v: %v\n", rec.ID, err)
+		output, _ = executeTemplate(defaultTemplate, data)
+	}
+	return output
+}
+
+func templateUsage(lang string) string {
+	return strings.Join([]string{
+		translate(lang, "Usage:"),
+		translate(lang, "/template - show the templates of the chat's notifications"),
+		translate(lang, "/template chat TEXT - set the template of the chat"),
+		translate(lang, "/template ID TEXT - set the template of a target"),
+		translate(lang, "/template chat off, /template ID off - go back to the default"),
+		translate(lang, "/preview [ID] - render the template with sample data"),
+		"",
+		translate(lang, "Templates use Go template syntax with Telegram HTML, e.g."),
+		"<code>{{.Emoji}} &lt;b&gt;{{.Target.Title}}&lt;/b&gt; is {{.Status.Type}}" +
+			"{{if .Recovery}} after {{duration .Duration}}{{end}}</code>",
+		"",
+		translate(
+			lang,
+			"Fields: .Target (.ID, .Title, .URL, .Tags, .Critical), "+
+				".Status and .PrevStatus (.Type, .OK, .Error, .ResponseTime, .HTTPStatusCode, .HTTPStatusText), "+
+				".Emoji, .Time, .IncidentStart, .Duration, .Recovery, .Verbosity, .HideDetails, .Language. "+
+				"Functions: repeat, tags, duration. "+
+				"Methods in the chat's language: .T for translation, .FormatTime, .FormatDuration."),
+	}, "\n")
+}
+
+// manageTemplates handles /template command.
+func (b *Bot) manageTemplates(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	internalError := func(err error) {
+		fmt.Println(err)
+		b.SendMessage(
+			message.Chat.ID,
+			translate(
+				lang,
+				"Error while changing the template, please contact the administrator: %v",
+				b.AdminNickname))
+	}
+
+	args := strings.TrimSpace(message.CommandArguments())
+	if args == "" {
+		b.sendTemplates(message.Chat.ID)
+		return
+	}
+	fields := strings.Fields(args)
+	scope := fields[0]
+	text := strings.TrimSpace(strings.TrimPrefix(args, scope))
+	if text == "" {
+		b.SendMessage(message.Chat.ID, templateUsage(lang))
+		return
+	}
+	if text == "off" {
+		text = ""
+	}
+	if text != "" {
+		if _, err := parseTemplate(text); err != nil {
+			b.SendMessage(message.Chat.ID, translate(
+				lang,
+				"Invalid template: %v\n\n%v", replaceHTML(translateError(lang, err)), templateUsage(lang)))
+			return
+		}
+	}
+
+	if scope == "chat" {
+		if !b.checkPermission(message, b.canChangeSettings) {
+			return
+		}
+		settings, err := b.getChatSettings(message.Chat.ID)
+		if err != nil {
+			internalError(err)
+			return
+		}
+		settings.Template = text
+		if err := b.DB.SaveChatSettings(settings); err != nil {
+			internalError(err)
+			return
+		}
+		b.SendMessage(message.Chat.ID, translate(lang, "Template of the chat was changed, see it with /preview"))
+		return
+	}
+
+	if _, err := strconv.Atoi(scope); err != nil {
+		b.SendMessage(message.Chat.ID, templateUsage(lang))
+		return
+	}
+	if !b.checkPermission(message, b.canManageTargets) {
+		return
+	}
+	target := b.getOwnedTarget(message, scope)
+	if target == nil {
+		return
+	}
+	before := *target
+	target.Template = text
+	if err := b.DB.UpdateTarget(*target); err != nil {
+		internalError(err)
+		return
+	}
+	b.audit(message, ActionEdit, &before, target)
+	b.SendMessage(message.Chat.ID, translate(
+		lang,
+		"Template of the target was changed, see it with /preview %v", target.ID))
+}
+
+// sendTemplates lists the templates set for the chat and its targets.
+func (b *Bot) sendTemplates(chatID int64) {
+	lang := b.chatLanguage(chatID)
+	settings, err := b.getChatSettings(chatID)
+	if err != nil {
+		fmt.Println(err)
+	}
+	var lines []string
+	if settings.Template != "" {
+		lines = append(lines, translate(lang, "<b>Chat:</b>")+"\n<code>"+replaceHTML(settings.Template)+"</code>")
+	} else if b.template != nil {
+		lines = append(lines, translate(lang, "<b>Chat:</b> the bot's default"))
+	} else {
+		lines = append(lines, translate(lang, "<b>Chat:</b> the built-in default"))
+	}
+
+	targets, err := b.DB.GetCurrentTargets(chatID)
+	if err != nil {
+		fmt.Println(err)
+	}
+	for _, target := range targets {
+		if target.Template != "" {
+			lines = append(lines, fmt.Sprintf(
+				"<b>%v %v:</b>\n<code>%v</code>",
+				target.ID, replaceHTML(target.Title), replaceHTML(target.Template)))
+		}
+	}
+	lines = append(lines, "", templateUsage(lang))
+	b.SendMessage(chatID, strings.Join(lines, "\n"))
+}
+
+// sendPreview handles /preview command, which renders the chat's or
+// the target's template with a sample failure and recovery.
+func (b *Bot) sendPreview(message *tgbotapi.Message) {
+	lang := b.chatLanguage(message.Chat.ID)
+	settings, err := b.getChatSettings(message.Chat.ID)
+	if err != nil {
+		fmt.Println(err)
+	}
+
+	rec := &Record{ID: 1, Title: "Example", URL: "http://example.com", Tags: "prod"}
+	if args := strings.TrimSpace(message.CommandArguments()); args != "" {
+		id, err := strconv.Atoi(args)
+		if err != nil {
+			b.SendMessage(message.Chat.ID, translate(lang, "Usage: /preview [ID]"))
+			return
+		}
+		rec, err = b.DB.GetTarget(id)
+		if err != nil || !b.isVisibleTo(rec, message.Chat.ID) {
+			b.SendMessage(message.Chat.ID, translate(lang, "No target with such ID found"))
+			return
+		}
+	}
+
+	tmpl := b.notificationTemplate(rec, settings)
+	for _, data := range sampleTemplateData(rec, settings, lang) {
+		output, err := executeTemplate(tmpl, data)
+		if err != nil {
+			output = translate(lang, "Error while rendering the template: %v", replaceHTML(translateError(lang, err)))
+		}
+		b.SendMessage(message.Chat.ID, output)
+	}
+}
@@ -0,0 +1,233 @@
+package telegrambot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Names, under which other uptime tools export the columns of monitor lists.
+var csvColumnAliases = map[string][]string{
+	"id":       {"id"},
+	"title":    {"title", "name", "friendly name", "friendly_name", "monitor name", "monitor_name", "display name"},
+	"url":      {"url", "monitor url", "monitor_url", "url/ip", "address", "target", "host", "hostname"},
+	"type":     {"type", "monitor type", "monitor_type", "check type", "kind"},
+	"paused":   {"paused"},
+	"status":   {"status", "state"},
+	"active":   {"active", "enabled"},
+	"critical": {"critical"},
+	"tags":     {"tags", "tag", "labels", "groups", "group"},
+	"interval": {"interval", "check interval", "check_interval", "frequency"},
+	"keyword":  {"keyword", "keyword value", "keyword_value"},
+	"template": {"template"},
+}
+
+// Values of status columns, which mean the monitor is paused.
+var pausedStatuses = []string{"paused", "inactive", "disabled", "stopped"}
+
+func isHTTPCheckType(checkType string) bool {
+	return checkType == "" || strings.Contains(checkType, "http") || checkType == "website"
+}
+
+// isTagSeparator reports whether the rune separates tags in a CSV column.
+// Other tools separate them with commas or semicolons.
+func isTagSeparator(r rune) bool {
+	return r == ' ' || r == ',' || r == ';'
+}
+
+// decodeCSVTargets parses CSV with a header. Besides the columns of /export
+// it understands monitor lists of other uptime tools: checks other than HTTP
+// are skipped, keyword checks are imported as plain HTTP checks.
+func decodeCSVTargets(data []byte) ([]targetDocument, []error, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, nil
+	}
+
+	header := map[string]int{}
+	native := true
+	for i, name := range rows[0] {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		header[name] = i
+		known := false
+		for _, column := range csvHeader {
+			known = known || name == column
+		}
+		native = native && known
+	}
+	columns := map[string]int{}
+	for column, aliases := range csvColumnAliases {
+		for _, alias := range aliases {
+			if i, ok := header[alias]; ok {
+				columns[column] = i
+				break
+			}
+		}
+	}
+	if _, ok := columns["url"]; !ok {
+		return nil, nil, errorf("CSV header must contain url column")
+	}
+	// IDs of other tools have nothing to do with IDs of our targets.
+	if !native {
+		delete(columns, "id")
+	}
+	get := func(row []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var docs []targetDocument
+	var skipped []error
+	for n, row := range rows[1:] {
+		rowName := fmt.Sprintf("row %v", n+1)
+		if title := get(row, "title"); title != "" {
+			rowName = fmt.Sprintf("row %v (%v)", n+1, title)
+		}
+
+		checkType := strings.ToLower(get(row, "type"))
+		if !isHTTPCheckType(checkType) && checkType != "keyword" {
+			skipped = append(skipped, errorf("%v: %v checks are not supported", rowName, checkType))
+			continue
+		}
+		if checkType == "keyword" || get(row, "keyword") != "" {
+			skipped = append(skipped, errorf(
+				"%v: keyword is not checked, imported as a plain HTTP check", rowName))
+		}
+
+		doc := targetDocument{
+			Title: get(row, "title"),
+			URL:   get(row, "url"),
+			Tags:  strings.FieldsFunc(get(row, "tags"), isTagSeparator),
+		}
+		if native {
+			doc.Template = get(row, "template")
+		}
+		if id := get(row, "id"); id != "" && id != "0" {
+			parsed, err := strconv.ParseUint(id, 10, 32)
+			if err != nil {
+				return nil, nil, errorf("row %v: invalid id %q", n+1, id)
+			}
+			doc.ID = uint(parsed)
+		}
+		for column, dst := range map[string]*bool{"paused": &doc.Paused, "critical": &doc.Critical} {
+			if value := get(row, column); value != "" {
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, nil, errorf("row %v: invalid %v value %q", n+1, column, value)
+				}
+				*dst = b
+			}
+		}
+		status := strings.ToLower(get(row, "status"))
+		for _, paused := range pausedStatuses {
+			doc.Paused = doc.Paused || status == paused
+		}
+		if active := strings.ToLower(get(row, "active")); active != "" {
+			isActive, err := strconv.ParseBool(active)
+			doc.Paused = doc.Paused || (err == nil && !isActive) || active == "no"
+		}
+		docs = append(docs, doc)
+	}
+
+	if _, ok := columns["interval"]; ok && len(docs) > 0 {
+		skipped = append(skipped, errorf("check intervals: all targets are polled with the bot's global interval"))
+	}
+	return docs, skipped, nil
+}
+
+// prometheusConfig is the part of Prometheus config relevant to blackbox
+// exporter jobs.
+type prometheusConfig struct {
+	ScrapeConfigs []scrapeConfig `yaml:"scrape_configs"`
+}
+
+type scrapeConfig struct {
+	JobName       string              `yaml:"job_name"`
+	MetricsPath   string              `yaml:"metrics_path"`
+	Params        map[string][]string `yaml:"params"`
+	StaticConfigs []struct {
+		Targets []string          `yaml:"targets"`
+		Labels  map[string]string `yaml:"labels"`
+	} `yaml:"static_configs"`
+	// The rest of the job's settings, used to find service discovery, which
+	// can't be imported.
+	Other map[string]interface{} `yaml:",inline"`
+}
+
+// isScrapeConfig reports whether the data is a Prometheus config with scrape
+// jobs.
+func isScrapeConfig(data []byte) bool {
+	var config prometheusConfig
+	return yaml.Unmarshal(data, &config) == nil && len(config.ScrapeConfigs) > 0
+}
+
+// decodeScrapeConfig imports static targets of blackbox exporter jobs from
+// Prometheus config. Targets of HTTP modules become targets of the bot,
+// targets of other modules and service discovery are skipped.
+func decodeScrapeConfig(data []byte) ([]targetDocument, []error, error) {
+	var config prometheusConfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, nil, err
+	}
+
+	var docs []targetDocument
+	var skipped []error
+	for _, job := range config.ScrapeConfigs {
+		if strings.TrimRight(job.MetricsPath, "/") != "/probe" {
+			skipped = append(skipped, errorf("job %v: not a blackbox exporter job", job.JobName))
+			continue
+		}
+		for key := range job.Other {
+			if strings.HasSuffix(key, "_sd_configs") {
+				skipped = append(skipped, errorf(
+					"job %v: targets from %v can't be imported, only static_configs", job.JobName, key))
+			}
+		}
+
+		module := "http_2xx"
+		if modules := job.Params["module"]; len(modules) > 0 {
+			module = modules[0]
+		}
+		lowerModule := strings.ToLower(module)
+		isHTTP := strings.Contains(lowerModule, "http")
+		if isHTTP && lowerModule != "http_2xx" {
+			skipped = append(skipped, errorf(
+				"job %v: settings of module %v are unknown, imported as a plain HTTP check",
+				job.JobName, module))
+		}
+
+		for _, static := range job.StaticConfigs {
+			for _, target := range static.Targets {
+				if !isHTTP {
+					skipped = append(skipped, errorf(
+						"job %v: %v uses module %v, which is not an HTTP check",
+						job.JobName, target, module))
+					continue
+				}
+
+				doc := targetDocument{Title: target, URL: target}
+				if len(static.Targets) == 1 {
+					for _, label := range []string{"instance", "title", "name"} {
+						if value := static.Labels[label]; value != "" {
+							doc.Title = value
+						}
+					}
+				}
+				docs = append(docs, doc)
+			}
+		}
+	}
+	return docs, skipped, nil
+}
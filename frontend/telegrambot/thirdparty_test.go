@@ -0,0 +1,130 @@
+package telegrambot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeThirdPartyCSV(t *testing.T) {
+	data := "\ufeffFriendly Name,URL,Type,Status,Tags,Interval,ID\n" +
+		"Main site,https://example.com,HTTP(s),Active,\"prod,web\",60,123\n" +
+		"Shop,https://shop.example.com,Keyword,Paused,,60,124\n" +
+		"Mail,mail.example.com,Port,Active,,60,125\n" +
+		"Blog,https://blog.example.com,website,inactive,blog;web,300,126\n"
+
+	docs, skipped, err := decodeCSVTargets([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []targetDocument{
+		// IDs of other tools are ignored.
+		{Title: "Main site", URL: "https://example.com", Tags: []string{"prod", "web"}},
+		{Title: "Shop", URL: "https://shop.example.com", Paused: true, Tags: []string{}},
+		{Title: "Blog", URL: "https://blog.example.com", Paused: true, Tags: []string{"blog", "web"}},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("Got %+v, want %+v", docs, want)
+	}
+
+	wantSkipped := []string{
+		"row 2 (Shop): keyword is not checked",
+		"row 3 (Mail): port checks are not supported",
+		"check intervals:",
+	}
+	if len(skipped) != len(wantSkipped) {
+		t.Fatalf("Skipped %v, want %v entries", skipped, len(wantSkipped))
+	}
+	for i, prefix := range wantSkipped {
+		if !strings.HasPrefix(skipped[i].Error(), prefix) {
+			t.Errorf("Skipped entry %v is %q, want %q...", i, skipped[i], prefix)
+		}
+	}
+}
+
+func TestDecodeCSVActiveColumn(t *testing.T) {
+	data := "name,url,enabled\nA,http://a.example.com,true\nB,http://b.example.com,no\nC,http://c.example.com,false\n"
+	docs, _, err := decodeCSVTargets([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paused []bool
+	for _, doc := range docs {
+		paused = append(paused, doc.Paused)
+	}
+	if !reflect.DeepEqual(paused, []bool{false, true, true}) {
+		t.Errorf("Paused flags are %v", paused)
+	}
+}
+
+const prometheusConfigSample = `
+global:
+  scrape_interval: 15s
+scrape_configs:
+  - job_name: node
+    static_configs:
+      - targets: ["localhost:9100"]
+  - job_name: blackbox
+    metrics_path: /probe
+    params:
+      module: [http_2xx]
+    static_configs:
+      - targets:
+          - https://example.com
+          - https://example.org
+      - targets: ["https://api.example.com/health"]
+        labels:
+          instance: API
+    file_sd_configs:
+      - files: [targets.json]
+  - job_name: blackbox-icmp
+    metrics_path: /probe
+    params:
+      module: [icmp]
+    static_configs:
+      - targets: [example.com]
+  - job_name: blackbox-post
+    metrics_path: /probe/
+    params:
+      module: [http_post_2xx]
+    static_configs:
+      - targets: ["https://example.com/form"]
+`
+
+func TestDecodeScrapeConfig(t *testing.T) {
+	if !isScrapeConfig([]byte(prometheusConfigSample)) {
+		t.Fatal("Prometheus config is not recognized")
+	}
+	if isScrapeConfig([]byte("- url: http://example.com\n")) {
+		t.Error("Export is recognized as Prometheus config")
+	}
+
+	docs, skipped, err := decodeTargetDocuments("prometheus.yml", []byte(prometheusConfigSample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []targetDocument{
+		{Title: "https://example.com", URL: "https://example.com"},
+		{Title: "https://example.org", URL: "https://example.org"},
+		{Title: "API", URL: "https://api.example.com/health"},
+		{Title: "https://example.com/form", URL: "https://example.com/form"},
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Errorf("Got %+v, want %+v", docs, want)
+	}
+
+	wantSkipped := []string{
+		"job node: not a blackbox exporter job",
+		"job blackbox: targets from file_sd_configs can't be imported",
+		"job blackbox-icmp: example.com uses module icmp",
+		"job blackbox-post: settings of module http_post_2xx are unknown",
+	}
+	if len(skipped) != len(wantSkipped) {
+		t.Fatalf("Skipped %v, want %v entries", skipped, len(wantSkipped))
+	}
+	for i, prefix := range wantSkipped {
+		if !strings.HasPrefix(skipped[i].Error(), prefix) {
+			t.Errorf("Skipped entry %v is %q, want %q...", i, skipped[i], prefix)
+		}
+	}
+}
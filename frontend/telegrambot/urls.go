@@ -0,0 +1,79 @@
+package telegrambot
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/idna"
+)
+
+// normalizeURL validates the URL entered by a user and brings it to
+// the canonical form: surrounding whitespace is trimmed, http:// is assumed
+// if the scheme is missing, the host is lower-cased and international domain
+// names are converted to punycode.
+func normalizeURL(raw string) (string, error) {
+	raw = strings.TrimFunc(raw, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '\u200b' || r == '\ufeff'
+	})
+	if raw == "" {
+		return "", errorf("URL is empty")
+	}
+	if strings.IndexFunc(raw, unicode.IsSpace) >= 0 {
+		return "", errorf("URL must not contain spaces")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", errorf("URL could not be parsed")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", errorf("only http and https URLs are supported")
+	}
+
+	hostname := u.Hostname()
+	if hostname == "" {
+		return "", errorf("URL has no host")
+	}
+	if net.ParseIP(hostname) == nil {
+		hostname, err = idna.ToASCII(strings.ToLower(hostname))
+		if err != nil {
+			return "", errorf("invalid domain name")
+		}
+		if !strings.Contains(hostname, ".") && hostname != "localhost" {
+			return "", errorf("domain name must contain a dot")
+		}
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(hostname, port)
+	} else if strings.Contains(hostname, ":") {
+		u.Host = "[" + hostname + "]"
+	} else {
+		u.Host = hostname
+	}
+
+	return u.String(), nil
+}
+
+// findTargetByURL returns the chat's target with the same URL, if any.
+func (b *Bot) findTargetByURL(chatID int64, rawURL string) (*Record, error) {
+	targs, err := b.DB.GetCurrentTargets(chatID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range targs {
+		normalized, err := normalizeURL(targs[i].URL)
+		if err != nil {
+			normalized = targs[i].URL
+		}
+		if normalized == rawURL {
+			return &targs[i], nil
+		}
+	}
+	return nil, nil
+}
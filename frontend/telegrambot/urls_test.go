@@ -0,0 +1,38 @@
+package telegrambot
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	cases := []struct {
+		raw, want string
+	}{
+		{"example.com", "http://example.com"},
+		{"  https://Example.COM/Path?q=1 \u200b", "https://example.com/Path?q=1"},
+		{"HTTP://example.com:8080/", "http://example.com:8080/"},
+		{"http://пример.рф/", "http://xn--e1afmkfd.xn--p1ai/"},
+		{"http://localhost:3000", "http://localhost:3000"},
+		{"http://192.0.2.1/", "http://192.0.2.1/"},
+		{"http://[2001:db8::1]:8080/", "http://[2001:db8::1]:8080/"},
+		{"http://[2001:db8::1]/", "http://[2001:db8::1]/"},
+	}
+	for _, c := range cases {
+		got, err := normalizeURL(c.raw)
+		if err != nil || got != c.want {
+			t.Errorf("normalizeURL(%q) = %q, %v, want %q", c.raw, got, err, c.want)
+		}
+	}
+
+	for _, raw := range []string{
+		"",
+		"   ",
+		"http://exa mple.com",
+		"ftp://example.com",
+		"http://",
+		"http://intranet/",
+		"http://%zz",
+	} {
+		if got, err := normalizeURL(raw); err == nil {
+			t.Errorf("normalizeURL(%q) = %q, want an error", raw, got)
+		}
+	}
+}
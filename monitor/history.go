@@ -0,0 +1,63 @@
+package monitor
+
+import "time"
+
+// HistoryStore is an interface of storage of polling history. If Monitor has
+// a HistoryStore, it saves there every status it receives, not only status
+// changes, so that the history can be used to compute uptime statistics.
+type HistoryStore interface {
+	AddStatus(t Target, s Status, at time.Time) error
+}
+
+// HistoryRecord is a status of a target received by Monitor at some time.
+type HistoryRecord struct {
+	Target Target
+	Status Status
+	Time   time.Time
+}
+
+// BatchHistoryStore is a HistoryStore, which can save several statuses at
+// once. Monitor uses AddStatuses instead of AddStatus if the store implements
+// it.
+type BatchHistoryStore interface {
+	HistoryStore
+	AddStatuses(records []HistoryRecord) error
+}
+
+// maxHistoryBatch is the maximum number of records saved at once.
+const maxHistoryBatch = 100
+
+// writeHistory saves the records received from the channel into the store
+// until the channel is closed. Records, which have piled up while the
+// previous batch was being saved, are saved together.
+func writeHistory(store HistoryStore, records <-chan HistoryRecord, errs chan<- error) {
+	for rec := range records {
+		batch := []HistoryRecord{rec}
+	collect:
+		for len(batch) < maxHistoryBatch {
+			select {
+			case rec, ok := <-records:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, rec)
+			default:
+				break collect
+			}
+		}
+
+		var err error
+		if bs, ok := store.(BatchHistoryStore); ok {
+			err = bs.AddStatuses(batch)
+		} else {
+			for _, rec := range batch {
+				if e := store.AddStatus(rec.Target, rec.Status, rec.Time); e != nil {
+					err = e
+				}
+			}
+		}
+		if err != nil && errs != nil {
+			errs <- err
+		}
+	}
+}
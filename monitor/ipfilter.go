@@ -0,0 +1,150 @@
+package monitor
+
+import (
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultDeniedNets are networks which should not be reachable by a poller
+// of a public bot: loopback, private, link-local (including cloud metadata
+// endpoints), carrier-grade NAT and other special-purpose ranges.
+var DefaultDeniedNets = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+}
+
+// ParseIPNets parses a list of networks in CIDR notation. Single addresses
+// are accepted as well and are treated as /32 or /128 networks.
+func ParseIPNets(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		if ip := net.ParseIP(cidr); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network %q", cidr)
+		}
+		nets = append(nets, ipnet)
+	}
+	return nets, nil
+}
+
+// IPFilter decides which IP addresses a Poller may connect to. It is applied
+// to every connection after DNS resolution, so it also covers redirects and
+// domains resolving to internal addresses.
+type IPFilter struct {
+	// Networks which are allowed even if they are contained in Deny.
+	Allow []*net.IPNet
+	// Networks which are not allowed.
+	Deny []*net.IPNet
+	// If not nil, addresses must be allowed by this filter as well.
+	And *IPFilter
+	// Optional check of host names of redirects, which stops the request if
+	// it returns an error.
+	CheckHost func(host string) error
+}
+
+// NewIPFilter constructs an IPFilter from lists of networks in CIDR notation.
+func NewIPFilter(allow, deny []string) (*IPFilter, error) {
+	allowNets, err := ParseIPNets(allow)
+	if err != nil {
+		return nil, err
+	}
+	denyNets, err := ParseIPNets(deny)
+	if err != nil {
+		return nil, err
+	}
+	return &IPFilter{Allow: allowNets, Deny: denyNets}, nil
+}
+
+func netsContain(nets []*net.IPNet, ip net.IP) bool {
+	for _, ipnet := range nets {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Allowed reports whether connections to the address are allowed.
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	if !netsContain(f.Allow, ip) && netsContain(f.Deny, ip) {
+		return false
+	}
+	return f.And == nil || f.And.Allowed(ip)
+}
+
+// checkHost runs CheckHost of the filter and of the filters it's chained to.
+func (f *IPFilter) checkHost(host string) error {
+	for ; f != nil; f = f.And {
+		if f.CheckHost == nil {
+			continue
+		}
+		if err := f.CheckHost(host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// control is used as net.Dialer.Control to check every address the dialer
+// is about to connect to.
+func (f *IPFilter) control(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return errors.Errorf("could not parse address %q", host)
+	}
+	if !f.Allowed(ip) {
+		return errors.Errorf("connections to %v are not allowed", ip)
+	}
+	return nil
+}
+
+// Transport returns an HTTP transport, which refuses to connect to addresses
+// not allowed by the filter. It can be used by other components making
+// requests to user-supplied URLs, e.g. webhooks.
+//
+// The transport ignores HTTP_PROXY and HTTPS_PROXY: the filter would only
+// see the proxy's address, while the proxy would connect anywhere.
+func (f *IPFilter) Transport(timeout time.Duration) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: f.control,
+	}
+	return &http.Transport{
+		Proxy:               nil,
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		DisableKeepAlives:   true,
+	}
+}
@@ -0,0 +1,126 @@
+package monitor
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseIPNets(t *testing.T) {
+	nets, err := ParseIPNets([]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::1", "fd00::/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "2001:db8::1/128", "fd00::/8"}
+	for i, ipnet := range nets {
+		if ipnet.String() != want[i] {
+			t.Errorf("Network %v is %v, want %v", i, ipnet, want[i])
+		}
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "example.com", "10.0.0/8"} {
+		if _, err := ParseIPNets([]string{invalid}); err == nil {
+			t.Errorf("ParseIPNets(%q) succeeded", invalid)
+		}
+	}
+}
+
+func TestIPFilterAllowed(t *testing.T) {
+	filter, err := NewIPFilter([]string{"10.1.0.0/16"}, DefaultDeniedNets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"169.254.169.254", false},
+		// IPv4-mapped IPv6 form of the metadata endpoint.
+		{"::ffff:169.254.169.254", false},
+		{"::ffff:a9fe:a9fe", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"fd12:3456::1", false},
+		{"fe80::1", false},
+		// Allowed networks win over denied ones.
+		{"10.1.2.3", true},
+		{"::ffff:10.1.2.3", true},
+	}
+	for _, c := range cases {
+		ip := net.ParseIP(c.ip)
+		if ip == nil {
+			t.Fatalf("Invalid IP %q", c.ip)
+		}
+		if allowed := filter.Allowed(ip); allowed != c.allowed {
+			t.Errorf("Allowed(%v) = %v, want %v", c.ip, allowed, c.allowed)
+		}
+	}
+}
+
+func TestIPFilterChain(t *testing.T) {
+	inner, err := NewIPFilter(nil, []string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer, err := NewIPFilter([]string{"10.0.0.0/8"}, []string{"192.0.2.0/24"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer.And = inner
+
+	// Allowing a network doesn't override the deny list of a chained filter.
+	for ip, allowed := range map[string]bool{
+		"10.1.2.3":      false,
+		"192.0.2.1":     false,
+		"93.184.216.34": true,
+	} {
+		if got := outer.Allowed(net.ParseIP(ip)); got != allowed {
+			t.Errorf("Allowed(%v) = %v, want %v", ip, got, allowed)
+		}
+	}
+}
+
+func TestIPFilterTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	filter, err := NewIPFilter(nil, DefaultDeniedNets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: filter.Transport(time.Second)}
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Error("Request to a loopback address succeeded")
+	}
+
+	filter, err = NewIPFilter([]string{"127.0.0.0/8"}, DefaultDeniedNets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client = &http.Client{Transport: filter.Transport(time.Second)}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Request to an allowed address failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestIPFilterIgnoresProxy(t *testing.T) {
+	filter, err := NewIPFilter(nil, DefaultDeniedNets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.Transport(time.Second).Proxy != nil {
+		t.Error("Filtered transport uses a proxy")
+	}
+}
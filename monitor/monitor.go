@@ -3,6 +3,8 @@ package monitor
 import (
 	"context"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
 // Monitor is a wrapper around Scheduler, which saves statuses into a StatusStore
@@ -14,6 +16,13 @@ type Monitor struct {
 	// internal storage (SimpleStore), but you might want to use some other
 	// backend like Redis with RedisStore.
 	StatusStore StatusStore
+	// Optional storage of polling history. If it is nil, the history is not
+	// kept. Statuses are saved in the background, so that a slow store does
+	// not stall the polling.
+	History HistoryStore
+	// Number of statuses waiting to be saved into History, after which new
+	// statuses are dropped.
+	HistoryBuffer int
 	// Expiration time for status values in the store.
 	ExpirationTime time.Duration
 	// If this flag describes how the monitor reacts to new statuses.
@@ -25,12 +34,13 @@ type Monitor struct {
 	NotifyFirstOK bool
 	// Channel by which the monitor will send all status changes.
 	// Whenever a type of a target's status (Status.Type) changes, monitor will
-	// send the target and its _new_ status into this channel.
+	// send the target, its _new_ status and the previous one into this channel.
 	// If the caller ignores this channel and does not read values from it,
 	// the monitor (and its scheduler) will clobber and stop doing status checks.
-	Updates chan TargetStatus
+	Updates chan StatusUpdate
 
-	errors chan error
+	errors  chan error
+	history chan HistoryRecord
 }
 
 // New creates a Monitor with given targets getter and default field values.
@@ -39,7 +49,8 @@ func New(targets TargetsGetter) *Monitor {
 		Scheduler:      NewScheduler(targets),
 		StatusStore:    SimpleStore{},
 		ExpirationTime: 30 * time.Second,
-		Updates:        make(chan TargetStatus),
+		HistoryBuffer:  1000,
+		Updates:        make(chan StatusUpdate),
 
 		errors: nil,
 	}
@@ -68,6 +79,12 @@ func (m *Monitor) Errors() chan error {
 func (m *Monitor) Run(ctx context.Context) {
 	go m.Scheduler.Run(ctx)
 
+	if m.History != nil {
+		m.history = make(chan HistoryRecord, m.HistoryBuffer)
+		defer close(m.history)
+		go writeHistory(m.History, m.history, m.errors)
+	}
+
 	var done <-chan struct{}
 	if ctx != nil {
 		done = ctx.Done()
@@ -108,7 +125,22 @@ func (m *Monitor) applyNewStatus(t Target, s Status) {
 	}
 
 	if m.isStatusNew(oldStatus, ok, s) {
-		m.Updates <- TargetStatus{t, s}
+		m.Updates <- StatusUpdate{
+			Target:     t,
+			Status:     s,
+			PrevStatus: oldStatus,
+			PrevOK:     ok,
+		}
 	}
 	m.StatusStore.SetStatus(t, s, m.ExpirationTime)
+
+	if m.history != nil {
+		select {
+		case m.history <- HistoryRecord{Target: t, Status: s, Time: time.Now()}:
+		default:
+			if m.errors != nil {
+				m.errors <- errors.Errorf("history buffer is full, status of %v is not saved", t.URL)
+			}
+		}
+	}
 }
@@ -4,8 +4,13 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
+// Maximum number of redirects the poller follows.
+const maxRedirects = 10
+
 // Poller makes HTTP request to some URL to return its availability status.
 type Poller struct {
 	// Timeout of network request.
@@ -13,6 +18,9 @@ type Poller struct {
 	// How many times should poller repeat the request if all previous ones
 	// ended in timeout.
 	TimeoutRetries int
+	// If not nil, the poller will only connect to addresses allowed by the
+	// filter, except when polling targets with AllowAnyAddress set.
+	IPFilter *IPFilter
 }
 
 // NewPoller constructs a new Poller with default fields.
@@ -27,9 +35,28 @@ func NewPoller() *Poller {
 // If there was an error during request, the returned Status structure will
 // contain information about the error.
 func (p *Poller) PollService(url string) Status {
+	return p.poll(url, p.IPFilter)
+}
+
+// PollTarget is similar to PollService, but it doesn't apply IPFilter if the
+// target is allowed to point at any address.
+func (p *Poller) PollTarget(t Target) Status {
+	if t.AllowAnyAddress {
+		return p.poll(t.URL, nil)
+	}
+	return p.poll(t.URL, p.IPFilter)
+}
+
+// PollWithFilter is similar to PollService, but it applies the given filter
+// instead of IPFilter. A nil filter allows any address.
+func (p *Poller) PollWithFilter(url string, filter *IPFilter) Status {
+	return p.poll(url, filter)
+}
+
+func (p *Poller) poll(url string, filter *IPFilter) Status {
 	retries := p.TimeoutRetries
 	for {
-		stat := p.pollServiceOnce(url)
+		stat := p.pollServiceOnce(url, filter)
 		if stat.Type != StatusTimeout {
 			return stat
 		}
@@ -40,10 +67,34 @@ func (p *Poller) PollService(url string) Status {
 	}
 }
 
-func (p *Poller) pollServiceOnce(url string) Status {
+func (p *Poller) pollServiceOnce(url string, filter *IPFilter) Status {
 	client := &http.Client{}
 	client.Timeout = p.Timeout
 
+	if filter != nil {
+		client.Transport = filter.Transport(p.Timeout)
+	}
+
+	var redirects []string
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return errors.Errorf("stopped after %v redirects", maxRedirects)
+		}
+		if filter != nil {
+			if err := filter.checkHost(req.URL.Hostname()); err != nil {
+				return err
+			}
+		}
+		redirects = append(redirects, req.URL.String())
+		return nil
+	}
+
+	status := p.doRequest(client, url)
+	status.Redirects = redirects
+	return status
+}
+
+func (p *Poller) doRequest(client *http.Client, url string) Status {
 	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
 		url = "http://" + url
 	}
@@ -63,9 +114,12 @@ func (p *Poller) pollServiceOnce(url string) Status {
 	}
 	defer resp.Body.Close()
 
+	var status Status
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return newHTTPErrorStatus(resp, dur)
+		status = newHTTPErrorStatus(resp, dur)
+	} else {
+		status = newSuccessStatus(resp, dur)
 	}
-
-	return newSuccessStatus(resp, dur)
+	status.Certificate = newCertificateInfo(resp.TLS)
+	return status
 }
@@ -93,7 +93,7 @@ func (s *Scheduler) PollTargets() {
 		workersDone.Add(1)
 		workersPool <- struct{}{}
 		go func() {
-			status := s.Poller.PollService(target.URL)
+			status := s.Poller.PollTarget(target)
 			if s.Statuses != nil {
 				s.Statuses <- TargetStatus{target, status}
 			}
@@ -1,6 +1,7 @@
 package monitor
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net"
 	"net/http"
@@ -90,6 +91,41 @@ type Status struct {
 	ResponseTime time.Duration
 	// If HTTP response was not received, it's set to 0.
 	HTTPStatusCode int
+	// URLs the request was redirected to, in order.
+	Redirects []string
+	// The server's certificate, if the connection used TLS. Otherwise nil.
+	Certificate *CertificateInfo
+}
+
+// CertificateInfo describes a TLS certificate presented by a server.
+type CertificateInfo struct {
+	Subject   string
+	Issuer    string
+	NotBefore time.Time
+	NotAfter  time.Time
+	// DNS names the certificate is valid for.
+	DNSNames []string
+}
+
+func (ci CertificateInfo) String() string {
+	return fmt.Sprintf(
+		"%v (issued by %v, valid %v - %v)",
+		ci.Subject, ci.Issuer,
+		ci.NotBefore.Format("2006-01-02"), ci.NotAfter.Format("2006-01-02"))
+}
+
+func newCertificateInfo(state *tls.ConnectionState) *CertificateInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := state.PeerCertificates[0]
+	return &CertificateInfo{
+		Subject:   cert.Subject.CommonName,
+		Issuer:    cert.Issuer.CommonName,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		DNSNames:  cert.DNSNames,
+	}
 }
 
 // ExpandedString returns a multi-line string, describing contents of the status
@@ -109,15 +145,27 @@ func (s Status) ExpandedString() string {
 		httpStatusText = "nil"
 	}
 
+	redirectsText := "nil"
+	if len(s.Redirects) > 0 {
+		redirectsText = strings.Join(s.Redirects, " -> ")
+	}
+
+	certText := "nil"
+	if s.Certificate != nil {
+		certText = s.Certificate.String()
+	}
+
 	template := `Status {
   Type = %v,
   Err = %v,
   Response Time = %v,
   HTTP Status = %v,
+  Redirects = %v,
+  Certificate = %v,
 }`
 	return fmt.Sprintf(
 		template,
-		s.Type, errText, s.ResponseTime, httpStatusText,
+		s.Type, errText, s.ResponseTime, httpStatusText, redirectsText, certText,
 	)
 }
 
@@ -11,6 +11,12 @@ type Target struct {
 	Title string
 	// The HTTP URL to poll.
 	URL string
+	// If true, Poller.IPFilter is not applied to this target.
+	AllowAnyAddress bool
+	// User-supplied labels of the target, used for display and grouping.
+	Tags []string
+	// Whether failures of the target are urgent, used by notifiers.
+	Critical bool
 }
 
 func (t Target) String() string {
@@ -27,6 +33,24 @@ func (ts TargetStatus) String() string {
 	return fmt.Sprintf("%v : %v", ts.Target, ts.Status)
 }
 
+// StatusUpdate describes a change of a target's status.
+type StatusUpdate struct {
+	Target Target
+	// The new status of the target.
+	Status Status
+	// The status of the target before the change. If the target had no status
+	// before (it is a new target or its status has expired), PrevOK is false.
+	PrevStatus Status
+	PrevOK     bool
+}
+
+func (su StatusUpdate) String() string {
+	if !su.PrevOK {
+		return fmt.Sprintf("%v : %v", su.Target, su.Status)
+	}
+	return fmt.Sprintf("%v : %v -> %v", su.Target, su.PrevStatus, su.Status)
+}
+
 // TargetsGetter is an interface of targets source. Monitor uses it to retrieve
 // list targets on every polling iteration. External frontend may implement
 // this interface to store targets in a DB or in a configuration file.